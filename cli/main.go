@@ -2,8 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"herbarium/lib"
 
@@ -13,32 +23,132 @@ import (
 func main() {
 	lib.InitLocales()
 
-	cmd := &cli.Command{
+	if err := newCommand().Run(context.Background(), os.Args); err != nil {
+		fmt.Println(lib.T_("Error:"), err)
+		os.Exit(1)
+	}
+}
+
+// newCommand builds the command line, with every subcommand.
+func newCommand() *cli.Command {
+	return &cli.Command{
 		Name:  "herbarium",
-		Usage: lib.T_("Manager for Everlasting Summer mods"),
+		Usage: lib.T_("Manager for Ren'Py Steam Workshop mods"),
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "no-gui",
+				Usage:       lib.T_("Do not hand commands over to a running Herbarium window"),
+				Destination: &noGUI,
+			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   lib.T_("Show debug messages"),
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Aliases: []string{"q"},
+				Usage:   lib.T_("Only show warnings and errors"),
+			},
+			&cli.StringFlag{
+				Name:    "game",
+				Usage:   lib.T_("Game to manage, see `herbarium games`"),
+				Value:   lib.DefaultGameID,
+				Sources: cli.EnvVars("HERBARIUM_GAME"),
+			},
+		},
+		Before: func(ctx context.Context, c *cli.Command) (context.Context, error) {
+			level := slog.LevelInfo
+			switch {
+			case c.Bool("verbose"):
+				level = slog.LevelDebug
+			case c.Bool("quiet"):
+				level = slog.LevelWarn
+			}
+			if _, err := lib.SetupLogging(os.Stderr, level); err != nil {
+				fmt.Fprintln(os.Stderr, lib.T_("cannot open log file:"), err)
+			}
+
+			return ctx, lib.SelectGame(c.String("game"))
+		},
 		Commands: []*cli.Command{
+			{
+				Name:  "games",
+				Usage: lib.T_("List the games Herbarium knows about"),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					games, err := lib.Games()
+					if err != nil {
+						return err
+					}
+					for _, g := range games {
+						mark := " "
+						if g.ID == lib.CurrentGame().ID {
+							mark = "*"
+						}
+						fmt.Printf("%s %-12s %-10s %s\n", mark, g.ID, g.AppID, g.Name)
+					}
+					return nil
+				},
+			},
+
 			{
 				Name:    "list",
 				Aliases: []string{"ls"},
 				Usage:   lib.T_("List known mods"),
 				Action: func(ctx context.Context, c *cli.Command) error {
-					cfg, err := lib.EnsureConfig()
+					m, err := loadLibrary(ctx)
 					if err != nil {
 						return err
 					}
 
-					db, err := lib.EnsureModsDB()
+					if err := m.Save(); err != nil {
+						return err
+					}
+
+					lib.PrintMods(m.DB)
+					return nil
+				},
+			},
+
+			{
+				Name:  "rescan",
+				Usage: lib.T_("Read the names of new and changed mods"),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "full",
+						Usage: lib.T_("Read every mod again, not only changed ones"),
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					m, err := openManager()
 					if err != nil {
 						return err
 					}
 
-					lib.ScanAndUpdate(cfg, db)
+					before := map[string]string{}
+					for _, mod := range m.DB.Mods {
+						before[mod.Folder] = mod.Name
+					}
 
-					if err := lib.SaveModsDB(db); err != nil {
+					if err := m.Scan(ctx, c.Bool("full")); err != nil {
+						return err
+					}
+					if err := m.Save(); err != nil {
 						return err
 					}
 
-					lib.PrintMods(db)
+					for _, mod := range m.DB.Mods {
+						old, known := before[mod.Folder]
+						if !known {
+							fmt.Println(lib.T_("New:"), mod.Folder, mod.Name)
+						} else if old != mod.Name {
+							fmt.Printf("%s: %s → %s\n", mod.Folder, old, mod.Name)
+						}
+					}
+					fmt.Println(lib.Format(lib.N_("{count} mod in the library", "{count} mods in the library", len(m.DB.Mods)),
+						lib.Args{"count": len(m.DB.Mods)}))
+
+					notifyGUI()
 					return nil
 				},
 			},
@@ -49,7 +159,7 @@ func main() {
 				Usage:     lib.T_("Disable mod by numeric folder, codename, or ALL"),
 				ArgsUsage: "<id>",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					return lib.ToggleEnabled(false, c.Args().First())
+					return toggleEnabled(ctx, false, c.Args().First())
 				},
 			},
 
@@ -59,7 +169,108 @@ func main() {
 				Usage:     lib.T_("Enable mod by numeric folder, codename, or ALL"),
 				ArgsUsage: "<id>",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					return lib.ToggleEnabled(true, c.Args().First())
+					return toggleEnabled(ctx, true, c.Args().First())
+				},
+			},
+
+			{
+				Name:      "verify",
+				Usage:     lib.T_("Check mod files against the checksums recorded when they were installed or updated"),
+				ArgsUsage: "[id|ALL]",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					id := c.Args().First()
+					if id == "" {
+						id = "ALL"
+					}
+
+					m, err := loadLibrary(ctx)
+					if err != nil {
+						return err
+					}
+					results, err := m.Verify(ctx, id)
+					if err != nil {
+						return err
+					}
+
+					failed := 0
+					for _, r := range results {
+						switch {
+						case r.Recorded:
+							fmt.Printf("➖ %-12s %s  %s\n", r.Mod.Folder, r.Mod.DisplayName(), lib.T_("recorded now, nothing to compare with"))
+						case r.Err != nil:
+							failed++
+							fmt.Printf("⚠️ %-12s %s  %v\n", r.Mod.Folder, r.Mod.DisplayName(), r.Err)
+						case r.Report.OK():
+							fmt.Printf("✅ %-12s %s\n", r.Mod.Folder, r.Mod.DisplayName())
+						default:
+							failed++
+							fmt.Printf("❌ %-12s %s  %s\n", r.Mod.Folder, r.Mod.DisplayName(), r.Report)
+							printPaths(lib.T_("missing:"), r.Report.Missing)
+							printPaths(lib.T_("extra:"), r.Report.Extra)
+							printPaths(lib.T_("modified:"), r.Report.Modified)
+						}
+					}
+
+					if failed > 0 {
+						return errors.New(lib.Format(lib.N_("{count} mod failed verification", "{count} mods failed verification", failed),
+							lib.Args{"count": failed}))
+					}
+					return nil
+				},
+			},
+
+			{
+				Name:  "conflicts",
+				Usage: lib.T_("List mods Steam downloaded again while they were disabled, and where the other copy went"),
+				Action: func(ctx context.Context, c *cli.Command) error {
+					conflicts, err := lib.LoadRestoreConflicts()
+					if err != nil {
+						return err
+					}
+					if len(conflicts) == 0 {
+						fmt.Println(lib.T_("No restore conflicts."))
+						return nil
+					}
+					for _, rc := range conflicts {
+						fmt.Println(rc.At.Format("2006-01-02 15:04"), rc.String())
+					}
+					return nil
+				},
+			},
+
+			{
+				Name:      "rename",
+				Usage:     lib.T_("Set the name shown for a mod, or reset it when no name is given"),
+				ArgsUsage: "<id> [name]",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					id, name := c.Args().Get(0), c.Args().Get(1)
+					return editMod(ctx, id, func(m *lib.Manager) error {
+						return m.Rename(id, name)
+					})
+				},
+			},
+
+			{
+				Name:      "alias",
+				Usage:     lib.T_("Set another codename for a mod, or reset it when none is given"),
+				ArgsUsage: "<id> [codename]",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					id, alias := c.Args().Get(0), c.Args().Get(1)
+					return editMod(ctx, id, func(m *lib.Manager) error {
+						return m.SetAlias(id, alias)
+					})
+				},
+			},
+
+			{
+				Name:      "set-cover",
+				Usage:     lib.T_("Use an image as the cover of a mod, or go back to the Workshop preview when none is given"),
+				ArgsUsage: "<id> [image]",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					id, image := c.Args().Get(0), c.Args().Get(1)
+					return editMod(ctx, id, func(m *lib.Manager) error {
+						return m.SetCover(id, image)
+					})
 				},
 			},
 
@@ -67,36 +278,767 @@ func main() {
 				Name:    "launch",
 				Aliases: []string{"start", "l"},
 				Usage:   lib.T_("Launch game with current mod setup"),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: lib.T_("Print the folders that would be moved and exit"),
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					// The plan comes from the stored database: a scan
+					// could ask Steam for titles and write manifests.
+					if c.Bool("dry-run") {
+						m, err := openManager()
+						if err != nil {
+							return err
+						}
+						fmt.Print(m.Plan(nil))
+						return nil
+					}
+
+					if handled, err := activateGUIAction("launch"); handled {
+						return err
+					}
+
+					m, err := loadLibrary(ctx)
+					if err != nil {
+						return err
+					}
+
+					if err := m.Save(); err != nil {
+						return err
+					}
+
+					// Ctrl+C stops waiting for the game; Launch then puts
+					// the mods and saves back before returning.
+					ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+					defer stop()
+
+					onEvent := m.OnEvent
+					m.OnEvent = func(e lib.Event) {
+						if e.Kind == lib.EventCrashed {
+							printCrashReport(e.Crash)
+						}
+						if onEvent != nil {
+							onEvent(e)
+						}
+					}
+					return m.Launch(ctx, nil)
+				},
+			},
+
+			{
+				Name:  "crashes",
+				Usage: lib.T_("List the last game crashes and the mods that probably caused them"),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "traceback",
+						Usage: lib.T_("Print the full traceback of the last crash"),
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					reports, err := lib.LoadCrashReports()
+					if err != nil {
+						return err
+					}
+					if len(reports) == 0 {
+						fmt.Println(lib.T_("No crashes recorded."))
+						return nil
+					}
+
+					if c.Bool("traceback") {
+						fmt.Print(reports[len(reports)-1].Traceback)
+						return nil
+					}
+					for _, r := range reports {
+						fmt.Println(r.At.Format("2006-01-02 15:04"), r.Summary())
+						printCrashReport(&r)
+					}
+					return nil
+				},
+			},
+
+			{
+				Name:  "bisect",
+				Usage: lib.T_("Find the mods that crash the game by launching with half of them at a time"),
+				Commands: []*cli.Command{
+					{
+						Name:  "start",
+						Usage: lib.T_("Start searching the enabled mods and launch the first step"),
+						Flags: []cli.Flag{noLaunchFlag},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							m, err := loadLibrary(ctx)
+							if err != nil {
+								return err
+							}
+							b, err := m.StartBisect()
+							if err != nil {
+								return err
+							}
+							return bisectStep(ctx, c, m, b)
+						},
+					},
+					{
+						Name:  "good",
+						Usage: lib.T_("Tell that the game worked with the current step and launch the next one"),
+						Flags: []cli.Flag{noLaunchFlag},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return markBisect(ctx, c, false)
+						},
+					},
+					{
+						Name:  "bad",
+						Usage: lib.T_("Tell that the game crashed with the current step and launch the next one"),
+						Flags: []cli.Flag{noLaunchFlag},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return markBisect(ctx, c, true)
+						},
+					},
+					{
+						Name:  "skip",
+						Usage: lib.T_("Tell that the current step showed nothing and launch another one"),
+						Flags: []cli.Flag{noLaunchFlag},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							m, err := loadLibrary(ctx)
+							if err != nil {
+								return err
+							}
+							b, err := m.SkipBisect()
+							if err != nil {
+								return err
+							}
+							return bisectStep(ctx, c, m, b)
+						},
+					},
+					{
+						Name:  "reset",
+						Usage: lib.T_("Stop searching; the saved mod selection was never changed"),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return lib.ResetBisect()
+						},
+					},
+				},
+			},
+
+			{
+				Name:  "serve",
+				Usage: lib.T_("Serve an HTTP API to manage mods and launch the game from another device"),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "listen",
+						Usage: lib.T_("Address to listen on"),
+						Value: "127.0.0.1:7420",
+					},
+					&cli.StringFlag{
+						Name:    "token",
+						Usage:   lib.T_("Token clients must send; a random one is made when empty"),
+						Sources: cli.EnvVars("HERBARIUM_TOKEN"),
+					},
+					&cli.BoolFlag{
+						Name:  "web",
+						Usage: lib.T_("Also serve a small control page"),
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					m, err := loadLibrary(ctx)
+					if err != nil {
+						return err
+					}
+					if err := m.Save(); err != nil {
+						return err
+					}
+
+					token := c.String("token")
+					if token == "" {
+						b := make([]byte, 16)
+						if _, err := rand.Read(b); err != nil {
+							return err
+						}
+						token = hex.EncodeToString(b)
+					}
+
+					ln, err := net.Listen("tcp", c.String("listen"))
+					if err != nil {
+						return err
+					}
+					if host, _, _ := net.SplitHostPort(c.String("listen")); !isLoopback(host) {
+						slog.Warn(lib.T_("the API is served without TLS; use it on trusted networks only"))
+					}
+
+					ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+					defer stop()
+
+					api := lib.NewServer(ctx, m, token)
+					api.CoverCache = guiAppID
+					api.Web = c.Bool("web")
+					api.OnChange = notifyGUI
+
+					url := "http://" + ln.Addr().String()
+					fmt.Println(lib.T_("Listening on"), url)
+					fmt.Println(lib.T_("Token:"), token)
+					if api.Web {
+						fmt.Println(lib.T_("Control page:"), url+"/#token="+token)
+					}
+
+					srv := &http.Server{Handler: api}
+					go func() {
+						<-ctx.Done()
+						shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+						defer cancel()
+						srv.Shutdown(shutdownCtx)
+					}()
+					if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+						return err
+					}
+
+					// A launch in progress has been interrupted; wait for
+					// it to put the mods back.
+					api.Wait()
+					return nil
+				},
+			},
+
+			{
+				Name:  "doctor",
+				Usage: lib.T_("Check Steam libraries, paths and launcher settings"),
 				Action: func(ctx context.Context, c *cli.Command) error {
 					cfg, err := lib.EnsureConfig()
 					if err != nil {
 						return err
 					}
 
-					if cfg.GameExe == "" {
-						return fmt.Errorf(lib.T_("game_exe is empty in config"))
+					lib.PrintDiagnostics(lib.Diagnose(cfg))
+					return nil
+				},
+			},
+
+			{
+				Name:  "export",
+				Usage: lib.T_("Export enabled mods or a profile as a shareable list"),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   lib.T_("Write the list to a .yaml or .json file"),
+					},
+					&cli.StringFlag{
+						Name:    "profile",
+						Aliases: []string{"p"},
+						Usage:   lib.T_("Export this profile instead of the enabled mods"),
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					m, err := loadLibrary(ctx)
+					if err != nil {
+						return err
+					}
+					db := m.DB
+
+					list, err := lib.ExportModList(db, c.String("profile"))
+					if err != nil {
+						return err
+					}
+
+					if out := c.String("output"); out != "" {
+						if err := lib.WriteModList(list, out); err != nil {
+							return err
+						}
+						fmt.Println(lib.Format(lib.N_("Wrote {count} mod to {file}", "Wrote {count} mods to {file}", len(list.Mods)),
+							lib.Args{"count": len(list.Mods), "file": out}))
+					}
+
+					fmt.Println(lib.EncodeModList(list))
+					return nil
+				},
+			},
+
+			{
+				Name:      "import",
+				Usage:     lib.T_("Apply a mod list from a file or a copy-paste string"),
+				ArgsUsage: "<file|string>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "profile",
+						Aliases: []string{"p"},
+						Usage:   lib.T_("Save the list as this profile instead of applying it"),
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if c.Args().First() == "" {
+						return errors.New(lib.T_("provide a file or a mod list string"))
+					}
+
+					m, err := loadLibrary(ctx)
+					if err != nil {
+						return err
+					}
+					db := m.DB
+
+					list, err := lib.ReadModList(c.Args().First())
+					if err != nil {
+						return err
 					}
 
-					db, err := lib.EnsureModsDB()
+					res, err := lib.ImportModList(db, list, c.String("profile"))
 					if err != nil {
 						return err
 					}
 
-					lib.ScanAndUpdate(cfg, db)
+					if err := m.Save(); err != nil {
+						return err
+					}
+					notifyGUI()
+
+					fmt.Println(lib.Format(lib.N_("Applied {applied} of {total} mod", "Applied {applied} of {total} mods", len(list.Mods)),
+						lib.Args{"applied": len(res.Applied), "total": len(list.Mods)}))
+					if len(res.Missing) > 0 {
+						fmt.Println(lib.T_("Not installed:"))
+						for _, m := range res.Missing {
+							name := m.Name
+							if name == "" {
+								name = m.ID
+							}
+							fmt.Printf("  %s  %s\n", name, lib.WorkshopURL(m.ID))
+						}
+					}
+					return nil
+				},
+			},
+
+			{
+				Name:      "collection",
+				Usage:     lib.T_("Compare a Steam Workshop collection with the installed mods"),
+				ArgsUsage: "<id|url>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "profile",
+						Aliases: []string{"p"},
+						Usage:   lib.T_("Create a profile with exactly the collection's mods enabled"),
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					id := lib.ParseWorkshopID(c.Args().First())
+					if id == "" {
+						return errors.New(lib.T_("provide a collection id or url"))
+					}
 
-					if err := lib.SaveModsDB(db); err != nil {
+					m, err := loadLibrary(ctx)
+					if err != nil {
 						return err
 					}
+					db := m.DB
 
-					lib.LaunchWithMods(cfg, db)
+					list, err := lib.FetchCollection(ctx, m.Config, id)
+					if err != nil {
+						return err
+					}
+
+					diff := lib.DiffCollection(db, list)
+					if list.Name != "" {
+						fmt.Println(list.Name)
+					}
+					for _, m := range diff.Installed {
+						fmt.Printf("✅ %-12s %s\n", m.ID, m.Name)
+					}
+					for _, m := range diff.Missing {
+						fmt.Printf("❌ %-12s %s  %s\n", m.ID, m.Name, lib.WorkshopURL(m.ID))
+					}
+					for _, m := range diff.Extra {
+						fmt.Printf("➕ %-12s %s\n", m.Folder, m.Name)
+					}
+					fmt.Println(lib.Format(lib.T_("Installed: {installed}, missing: {missing}, extra: {extra}"),
+						lib.Args{"installed": len(diff.Installed), "missing": len(diff.Missing), "extra": len(diff.Extra)}))
+
+					if name := c.String("profile"); name != "" {
+						folders := make([]string, 0, len(diff.Installed))
+						for _, m := range diff.Installed {
+							folders = append(folders, m.ID)
+						}
+						if err := lib.SaveProfile(db, name, folders); err != nil {
+							return err
+						}
+						if err := m.Save(); err != nil {
+							return err
+						}
+						notifyGUI()
+						fmt.Printf(lib.T_("Saved profile %s")+"\n", name)
+					}
 					return nil
 				},
 			},
+
+			{
+				Name:  "saves",
+				Usage: lib.T_("Back up and restore saves and persistent data"),
+				Commands: []*cli.Command{
+					{
+						Name:      "backup",
+						Usage:     lib.T_("Copy the current saves into a backup"),
+						ArgsUsage: "[name]",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := lib.EnsureConfig()
+							if err != nil {
+								return err
+							}
+
+							name, err := lib.BackupSaves(cfg, c.Args().First())
+							if err != nil {
+								return err
+							}
+							fmt.Println(lib.T_("Saved backup"), name)
+							return nil
+						},
+					},
+					{
+						Name:      "restore",
+						Usage:     lib.T_("Replace the current saves with a backup"),
+						ArgsUsage: "<name>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := lib.EnsureConfig()
+							if err != nil {
+								return err
+							}
+
+							if recovered, err := lib.RecoverSaves(); err != nil {
+								return err
+							} else if recovered {
+								fmt.Println(lib.T_("Saves from an interrupted session were put back."))
+							}
+
+							if c.Args().First() == "" {
+								return nil
+							}
+							return lib.RestoreSaves(cfg, c.Args().First())
+						},
+					},
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   lib.T_("List save backups"),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							backups, err := lib.ListSaveBackups()
+							if err != nil {
+								return err
+							}
+
+							for _, b := range backups {
+								fmt.Printf("%-32s %s  %s\n", b.Name,
+									b.Time.Format("2006-01-02 15:04"), lib.FormatSize(b.Size))
+							}
+							return nil
+						},
+					},
+				},
+			},
+
+			{
+				Name:  "profile",
+				Usage: lib.T_("Manage named sets of enabled mods"),
+				Commands: []*cli.Command{
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   lib.T_("List profiles"),
+						Action: func(ctx context.Context, c *cli.Command) error {
+							db, err := lib.EnsureModsDB()
+							if err != nil {
+								return err
+							}
+
+							for _, p := range db.Profiles {
+								mark := " "
+								if p.Name == db.ActiveProfile {
+									mark = "*"
+								}
+								fmt.Printf("%s %s (%s)\n", mark, p.Name, lib.Format(
+									lib.N_("{count} mod", "{count} mods", len(p.Mods)), lib.Args{"count": len(p.Mods)}))
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "save",
+						Usage:     lib.T_("Save the enabled mods as a profile"),
+						ArgsUsage: "<name>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							m, err := loadLibrary(ctx)
+							if err != nil {
+								return err
+							}
+							db := m.DB
+
+							name := c.Args().First()
+							if err := lib.SaveProfile(db, name, lib.EnabledFolders(db)); err != nil {
+								return err
+							}
+							db.ActiveProfile = name
+							if err := m.Save(); err != nil {
+								return err
+							}
+							notifyGUI()
+							return nil
+						},
+					},
+					{
+						Name:      "use",
+						Usage:     lib.T_("Enable exactly the mods of a profile"),
+						ArgsUsage: "<name>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if handled, err := activateGUIAction("switch-profile", c.Args().First()); handled {
+								return err
+							}
+
+							m, err := loadLibrary(ctx)
+							if err != nil {
+								return err
+							}
+							return m.ApplyProfile(c.Args().First())
+						},
+					},
+					{
+						Name:      "delete",
+						Aliases:   []string{"rm"},
+						Usage:     lib.T_("Delete a profile"),
+						ArgsUsage: "<name>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							db, err := lib.EnsureModsDB()
+							if err != nil {
+								return err
+							}
+
+							if err := lib.DeleteProfile(db, c.Args().First()); err != nil {
+								return err
+							}
+							if err := lib.SaveModsDB(db); err != nil {
+								return err
+							}
+							notifyGUI()
+							return nil
+						},
+					},
+				},
+			},
 		},
 	}
+}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		fmt.Println(lib.T_("Error:"), err)
-		os.Exit(1)
+// loadLibrary opens the config and the mods database and brings the
+// latter up to date with the Workshop folder.
+func loadLibrary(ctx context.Context) (*lib.Manager, error) {
+	m, err := openManager()
+	if err != nil {
+		return nil, err
+	}
+	return m, m.Scan(ctx, false)
+}
+
+// openManager opens the library with scan progress shown on a terminal,
+// since reading new or changed folders can take a while.
+func openManager() (*lib.Manager, error) {
+	m, err := lib.OpenManager()
+	if err != nil {
+		return nil, err
+	}
+	if isTerminal(os.Stderr) {
+		m.OnEvent = progressPrinter()
+	}
+	return m, nil
+}
+
+// progressDelay keeps quick scans and copies quiet.
+const progressDelay = 300 * time.Millisecond
+
+// progressPrinter prints the progress of scans and of folders copied
+// across filesystems on one line of stderr.
+func progressPrinter() func(lib.Event) {
+	start := time.Now()
+	shown := false
+	return func(e lib.Event) {
+		var line string
+		var last bool
+		switch p := e.Progress; {
+		case e.Kind == lib.EventScanning && p.Phase == lib.ScanExtracting:
+			line = lib.Format(lib.T_("Scanning folders: {index}/{total}"), lib.Args{"index": p.Index, "total": p.Total})
+			last = p.Index == p.Total
+		case e.Kind == lib.EventScanning && p.Phase == lib.ScanHashing:
+			line = lib.Format(lib.T_("Recording file checksums: {index}/{total}"), lib.Args{"index": p.Index, "total": p.Total})
+			last = p.Index == p.Total
+		case e.Kind == lib.EventCopying && e.Copy.Total > 0:
+			c := e.Copy
+			line = lib.Format(lib.T_("Copying folders: {done}/{total}"), lib.Args{"done": lib.FormatSize(c.Done), "total": lib.FormatSize(c.Total)})
+			last = c.Done >= c.Total
+		default:
+			return
+		}
+		if !shown && time.Since(start) < progressDelay {
+			return
+		}
+		shown = true
+		// Pad over the end of a longer previous line.
+		fmt.Fprintf(os.Stderr, "\r%-40s", line)
+		if last {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+var noLaunchFlag = &cli.BoolFlag{
+	Name:  "no-launch",
+	Usage: lib.T_("Only print the next step instead of launching it"),
+}
+
+func markBisect(ctx context.Context, c *cli.Command, crashed bool) error {
+	m, err := loadLibrary(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := m.MarkBisect(crashed)
+	if err != nil {
+		return err
+	}
+	return bisectStep(ctx, c, m, b)
+}
+
+// bisectStep prints the state of a bisect and launches its next step. A
+// crash is what the step is looking for, so it is reported as a hint
+// rather than an error.
+func bisectStep(ctx context.Context, c *cli.Command, m *lib.Manager, b *lib.Bisect) error {
+	if b.Done() {
+		switch b.Result {
+		case lib.BisectFound:
+			fmt.Println(lib.N_("The mod that crashes the game", "The mods that crash the game", len(b.Culprits)) + ":")
+			printModNames(m, b.Culprits)
+			fmt.Println(lib.Format(lib.N_("Found in {count} step.", "Found in {count} steps.", b.Steps), lib.Args{"count": b.Steps}))
+		case lib.BisectNoCrash:
+			fmt.Println(lib.T_("The game did not crash with all the mods, so there is nothing to search for."))
+		case lib.BisectUnconfirmed:
+			fmt.Println(lib.T_("The game did not crash with only these mods, so the crash depends on something else or does not always happen:"))
+			printModNames(m, b.Culprits)
+		}
+		fmt.Println(lib.T_("Run `herbarium bisect reset` to finish."))
+		return nil
+	}
+
+	sel := b.Selection()
+	fmt.Println(lib.Format(lib.T_("Step {step}: launching with {count} of {total} mods, {left} still suspected"),
+		lib.Args{"step": b.Steps + 1, "count": len(sel), "total": len(b.Mods), "left": b.Left()}))
+	if c.Bool("no-launch") {
+		printModNames(m, sel)
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	crashed := false
+	onEvent := m.OnEvent
+	m.OnEvent = func(e lib.Event) {
+		if e.Kind == lib.EventCrashed {
+			crashed = true
+		}
+		if onEvent != nil {
+			onEvent(e)
+		}
+	}
+	err := m.Launch(ctx, sel)
+	var launchErr *lib.LaunchError
+	if err != nil && !(errors.As(err, &launchErr) && launchErr.Stage == lib.LaunchStageGame) {
+		return err
+	}
+
+	if crashed {
+		fmt.Println(lib.T_("The game crashed; run `herbarium bisect bad` to go on."))
+	} else {
+		fmt.Println(lib.T_("Run `herbarium bisect good` if the game worked, `herbarium bisect bad` if it crashed or `herbarium bisect skip` if you could not tell."))
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func printModNames(m *lib.Manager, folders []string) {
+	for _, folder := range folders {
+		name := ""
+		for _, mod := range m.DB.Mods {
+			if mod.Folder == folder {
+				name = mod.DisplayName()
+				break
+			}
+		}
+		fmt.Printf("    %-12s %s\n", folder, name)
+	}
+}
+
+// printCrashReport prints the details of a crash under its summary.
+func printCrashReport(r *lib.CrashReport) {
+	if r.Error != "" {
+		fmt.Println("    " + r.Error)
+	}
+	for _, s := range r.Suspects {
+		fmt.Printf("    %-12s %s  %s:%s\n", s.Folder, s.Name, s.File, s.Line)
+	}
+	if r.File != "" {
+		fmt.Println("    "+lib.T_("traceback:"), r.File)
+	}
+}
+
+func printPaths(label string, paths []string) {
+	for _, p := range paths {
+		fmt.Println("    "+label, p)
+	}
+}
+
+// editMod changes the metadata of one mod and asks a running GUI to pick
+// up the change.
+func editMod(ctx context.Context, id string, edit func(*lib.Manager) error) error {
+	if id == "" {
+		return errors.New(lib.T_("provide folder id or codename"))
+	}
+
+	m, err := loadLibrary(ctx)
+	if err != nil {
+		return err
+	}
+	if err := edit(m); err != nil {
+		return err
+	}
+
+	notifyGUI()
+	return nil
+}
+
+// toggleEnabled hands the change to a running GUI, so that its cards stay
+// in sync, and falls back to editing the database directly.
+func toggleEnabled(ctx context.Context, enable bool, id string) error {
+	if id == "" {
+		return &lib.MissingIDError{}
+	}
+
+	m, err := loadLibrary(ctx)
+	if err != nil {
+		return err
+	}
+
+	if id != "ALL" && !slices.ContainsFunc(m.DB.Mods, func(mod lib.ModEntry) bool {
+		return mod.Matches(id)
+	}) {
+		return &lib.ModNotFoundError{ID: id}
+	}
+
+	action := map[bool]string{true: "enable", false: "disable"}[enable]
+	if handled, err := activateGUIAction(action, id); handled {
+		return err
 	}
+	return m.SetEnabled(id, enable)
 }
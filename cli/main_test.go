@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSteam answers the Steam Web API calls Herbarium makes: collection
+// contains 111 and 222, and every item is titled after its ID.
+func fakeSteam(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /ISteamRemoteStorage/GetCollectionDetails/v1/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"response": map[string]any{
+			"collectiondetails": []any{map[string]any{
+				"publishedfileid": r.FormValue("publishedfileids[0]"),
+				"result":          1,
+				"children": []any{
+					map[string]any{"publishedfileid": "111", "filetype": 0},
+					map[string]any{"publishedfileid": "222", "filetype": 0},
+				},
+			}},
+		}})
+	})
+	mux.HandleFunc("POST /ISteamRemoteStorage/GetPublishedFileDetails/v1/", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		var files []any
+		for i := 0; ; i++ {
+			id := r.PostForm.Get(fmt.Sprintf("publishedfileids[%d]", i))
+			if id == "" {
+				break
+			}
+			files = append(files, map[string]any{"publishedfileid": id, "result": 1, "title": "Title " + id})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"response": map[string]any{"publishedfiledetails": files}})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// setupHome points the XDG directories at a temporary home with a Workshop
+// folder holding the given mods and a config using steamAPI.
+func setupHome(t *testing.T, steamAPI string, mods ...string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, v := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(v, filepath.Join(home, v))
+	}
+	t.Setenv("HERBARIUM_GAME", "")
+
+	root := filepath.Join(home, "workshop")
+	for _, mod := range mods {
+		if err := os.MkdirAll(filepath.Join(root, mod), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := filepath.Join(home, "XDG_CONFIG_HOME", "ru.ximper.Herbarium")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf("launcher: custom\ngame_exe: /bin/true\nworkshop_root: %s\ndisabled_dir: %s\nsteam_api: %s\n",
+		root, filepath.Join(home, "disabled"), steamAPI)
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run runs the command line with args and returns what it printed.
+func run(t *testing.T, args ...string) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	err = newCommand().Run(context.Background(), append([]string{"herbarium", "--no-gui", "--quiet"}, args...))
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return <-out
+}
+
+func TestCollection(t *testing.T) {
+	srv := fakeSteam(t)
+	setupHome(t, srv.URL, "111", "333")
+
+	out := run(t, "collection", "https://steamcommunity.com/sharedfiles/filedetails/?id=900")
+	for _, want := range []string{
+		"Title 900",
+		"✅ 111",
+		"❌ 222",
+		"➕ 333",
+		"Installed: 1, missing: 1, extra: 1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestLaunchDryRunDoesNotScan(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run asked Steam for %s", r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+	setupHome(t, srv.URL, "111")
+
+	out := run(t, "launch", "--dry-run")
+	if !strings.Contains(out, "No folders to move.") {
+		t.Errorf("output:\n%s", out)
+	}
+	manifests, _ := filepath.Glob(filepath.Join(os.Getenv("XDG_DATA_HOME"), "*", "*", "manifests", "*"))
+	if len(manifests) > 0 {
+		t.Errorf("dry run recorded manifests: %v", manifests)
+	}
+}
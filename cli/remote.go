@@ -0,0 +1,54 @@
+package main
+
+import (
+	"herbarium/lib"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	guiAppID      = "ru.ximper.Herbarium"
+	guiObjectPath = "/ru/ximper/Herbarium"
+)
+
+// noGUI is set by --no-gui to always work on the files directly.
+var noGUI bool
+
+// activateGUIAction runs an action of a running Herbarium GUI through the
+// org.gtk.Actions interface GApplication exports. It reports false when no
+// GUI instance owns the app name, so the caller should do the work itself.
+func activateGUIAction(action string, args ...string) (bool, error) {
+	// A window is assumed to manage the default game, so commands for
+	// other games are always carried out here.
+	if noGUI || lib.CurrentGame().ID != lib.DefaultGameID {
+		return false, nil
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return false, nil
+	}
+	defer conn.Close()
+
+	var running bool
+	err = conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, guiAppID).Store(&running)
+	if err != nil || !running {
+		return false, nil
+	}
+
+	params := []dbus.Variant{}
+	for _, a := range args {
+		params = append(params, dbus.MakeVariant(a))
+	}
+
+	call := conn.Object(guiAppID, guiObjectPath).Call(
+		"org.gtk.Actions.Activate", 0, action, params, map[string]dbus.Variant{},
+	)
+	return true, call.Err
+}
+
+// notifyGUI asks a running GUI to reload mods_db.yaml after the CLI changed
+// it. Errors are ignored: the GUI picks up the changes on next start anyway.
+func notifyGUI() {
+	activateGUIAction("rescan")
+}
@@ -3,6 +3,7 @@ module herbarium
 go 1.25.1
 
 require (
+	github.com/godbus/dbus/v5 v5.2.2
 	github.com/urfave/cli/v3 v3.6.1
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -13,4 +14,5 @@ require (
 	github.com/diamondburned/gotk4/pkg v0.3.2-0.20250703063411-16654385f59a // indirect
 	go4.org/unsafe/assume-no-moving-gc v0.0.0-20231121144256-b99613f794b6 // indirect
 	golang.org/x/sync v0.19.0 // indirect
+	golang.org/x/sys v0.27.0 // indirect
 )
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"herbarium/lib"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// setupActions registers the application actions. GApplication exports
+// them on the session bus under the app ID, which is how the CLI drives a
+// running GUI instead of editing mods_db.yaml behind its back.
+func (a *HerbariumApp) setupActions() {
+	a.addAction("launch", "", func(string) {
+		a.window().launch()
+	})
+	a.addAction("enable", "s", func(id string) {
+		a.window().setModsEnabled(id, true)
+	})
+	a.addAction("disable", "s", func(id string) {
+		a.window().setModsEnabled(id, false)
+	})
+	a.addAction("switch-profile", "s", func(name string) {
+		a.window().switchProfile(name)
+	})
+	a.addAction("rescan", "", func(string) {
+		a.window().reload(nil)
+	})
+}
+
+func (a *HerbariumApp) addAction(name, paramType string, activate func(string)) {
+	var vt *glib.VariantType
+	if paramType != "" {
+		vt = glib.NewVariantType(paramType)
+	}
+
+	action := gio.NewSimpleAction(name, vt)
+	action.ConnectActivate(func(param *glib.Variant) {
+		arg := ""
+		if param != nil {
+			arg = param.String()
+		}
+		activate(arg)
+	})
+	a.App.AddAction(action)
+}
+
+// window returns the main window, creating it if the app was started by
+// an action rather than activated.
+func (a *HerbariumApp) window() *HerbariumWindow {
+	if a.Window == nil {
+		a.Activate()
+	}
+	return a.Window
+}
+
+func (mw *HerbariumWindow) setModsEnabled(id string, enabled bool) {
+	if err := mw.Manager.SetEnabled(id, enabled); err != nil {
+		mw.toast(err.Error())
+		return
+	}
+	mw.syncCards(mw.Manager.DB)
+}
+
+func (mw *HerbariumWindow) switchProfile(name string) {
+	if err := mw.Manager.ApplyProfile(name); err != nil {
+		mw.toast(err.Error())
+		return
+	}
+
+	mw.syncCards(mw.Manager.DB)
+	mw.toast(lib.T_("Switched to profile") + " " + name)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"herbarium/lib"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// bisectWizard walks through a bisect: it launches each step and takes
+// the answer from the player or, if asked to, from crash detection.
+type bisectWizard struct {
+	mw     *HerbariumWindow
+	bisect *lib.Bisect
+
+	page       *adw.StatusPage
+	auto       *adw.SwitchRow
+	startBtn   *gtk.Button
+	launchBtn  *gtk.Button
+	goodBtn    *gtk.Button
+	badBtn     *gtk.Button
+	skipBtn    *gtk.Button
+	stopBtn    *gtk.Button
+	disableBtn *gtk.Button
+
+	launching bool
+	crashed   bool
+}
+
+// showBisect opens the bisect wizard, picking up a bisect started earlier
+// or from the CLI.
+func (mw *HerbariumWindow) showBisect() {
+	w := &bisectWizard{mw: mw}
+
+	w.page = adw.NewStatusPage()
+	w.page.SetVExpand(true)
+
+	w.auto = adw.NewSwitchRow()
+	w.auto.SetTitle(lib.T_("Answer from crash detection"))
+	w.auto.SetSubtitle(lib.T_("After each launch, a crash counts as bad and a clean exit as good"))
+	w.auto.SetActive(true)
+	group := adw.NewPreferencesGroup()
+	group.Add(w.auto)
+
+	w.startBtn = pillButton(lib.T_("Start"), true)
+	w.startBtn.ConnectClicked(w.start)
+	w.launchBtn = pillButton(lib.T_("Launch step"), true)
+	w.launchBtn.ConnectClicked(w.launch)
+	w.goodBtn = pillButton(lib.T_("It worked"), false)
+	w.goodBtn.ConnectClicked(func() { w.mark(false) })
+	w.badBtn = pillButton(lib.T_("It crashed"), false)
+	w.badBtn.ConnectClicked(func() { w.mark(true) })
+	w.skipBtn = pillButton(lib.T_("Can't tell"), false)
+	w.skipBtn.ConnectClicked(w.skip)
+	w.disableBtn = pillButton(lib.T_("Disable these mods"), true)
+	w.disableBtn.ConnectClicked(w.disableCulprits)
+	w.stopBtn = pillButton(lib.T_("Stop"), false)
+	w.stopBtn.ConnectClicked(w.stop)
+
+	answers := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	answers.SetHAlign(gtk.AlignCenter)
+	answers.Append(w.goodBtn)
+	answers.Append(w.badBtn)
+	answers.Append(w.skipBtn)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 12)
+	box.Append(group)
+	box.Append(w.startBtn)
+	box.Append(w.launchBtn)
+	box.Append(answers)
+	box.Append(w.disableBtn)
+	box.Append(w.stopBtn)
+
+	clamp := adw.NewClamp()
+	clamp.SetMaximumSize(400)
+	clamp.SetChild(box)
+	w.page.SetChild(clamp)
+
+	toolbar := adw.NewToolbarView()
+	toolbar.AddTopBar(adw.NewHeaderBar())
+	toolbar.SetContent(w.page)
+
+	if b, err := lib.LoadBisect(); err == nil {
+		w.bisect = b
+	} else if !errors.As(err, new(*lib.NoBisectError)) {
+		mw.toast(err.Error())
+	}
+	w.update()
+
+	mw.NavView.Push(adw.NewNavigationPage(toolbar, lib.T_("Find a crashing mod")))
+}
+
+func pillButton(label string, suggested bool) *gtk.Button {
+	btn := gtk.NewButtonWithLabel(label)
+	btn.AddCSSClass("pill")
+	if suggested {
+		btn.AddCSSClass("suggested-action")
+	}
+	btn.SetHAlign(gtk.AlignCenter)
+	return btn
+}
+
+// update shows the state of the bisect and the buttons that apply to it.
+func (w *bisectWizard) update() {
+	b := w.bisect
+	running := b != nil && !b.Done()
+
+	w.startBtn.SetVisible(b == nil)
+	w.launchBtn.SetVisible(running)
+	w.goodBtn.SetVisible(running)
+	w.badBtn.SetVisible(running)
+	w.skipBtn.SetVisible(running)
+	w.auto.SetVisible(b == nil || running)
+	w.disableBtn.SetVisible(b != nil && b.Result == lib.BisectFound)
+	w.stopBtn.SetVisible(b != nil)
+	w.stopBtn.SetLabel(lib.T_("Stop"))
+
+	for _, btn := range []*gtk.Button{w.startBtn, w.launchBtn, w.goodBtn, w.badBtn, w.skipBtn, w.disableBtn, w.stopBtn} {
+		btn.SetSensitive(!w.launching)
+	}
+
+	switch {
+	case b == nil:
+		w.page.SetIconName("system-search-symbolic")
+		w.page.SetTitle(lib.T_("Find a crashing mod"))
+		w.page.SetDescription(lib.T_("Herbarium launches the game with all the enabled mods, then with half of them at a time, and narrows down the ones that make it crash. Your saved selection is not changed."))
+	case w.launching:
+		w.page.SetDescription(lib.T_("Waiting for the game to exit…"))
+	case running:
+		w.page.SetIconName("system-search-symbolic")
+		w.page.SetTitle(lib.Format(lib.T_("Step {step}"), lib.Args{"step": b.Steps + 1}))
+		w.page.SetDescription(lib.Format(lib.T_("{count} of {total} mods will be enabled, {left} still suspected."),
+			lib.Args{"count": len(b.Selection()), "total": len(b.Mods), "left": b.Left()}))
+	case b.Result == lib.BisectNoCrash:
+		w.page.SetIconName("emblem-ok-symbolic")
+		w.page.SetTitle(lib.T_("No crash"))
+		w.page.SetDescription(lib.T_("The game did not crash with all the mods, so there is nothing to search for."))
+		w.stopBtn.SetLabel(lib.T_("Finish"))
+	case b.Result == lib.BisectUnconfirmed:
+		w.page.SetIconName("dialog-question-symbolic")
+		w.page.SetTitle(lib.T_("Crash not confirmed"))
+		w.page.SetDescription(lib.T_("The game did not crash with only these mods, so the crash depends on something else or does not always happen:") +
+			"\n" + w.modNames(b.Culprits))
+		w.stopBtn.SetLabel(lib.T_("Finish"))
+	default:
+		w.page.SetIconName("dialog-warning-symbolic")
+		w.page.SetTitle(lib.N_("The mod that crashes the game", "The mods that crash the game", len(b.Culprits)))
+		w.page.SetDescription(w.modNames(b.Culprits))
+		w.stopBtn.SetLabel(lib.T_("Finish"))
+	}
+}
+
+func (w *bisectWizard) modNames(folders []string) string {
+	var names []string
+	for _, folder := range folders {
+		name := folder
+		for _, mod := range w.mw.Manager.DB.Mods {
+			if mod.Folder == folder {
+				name = mod.DisplayName()
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "\n")
+}
+
+func (w *bisectWizard) start() {
+	b, err := w.mw.Manager.StartBisect()
+	if err != nil {
+		w.mw.toast(err.Error())
+		return
+	}
+	w.bisect = b
+	w.update()
+}
+
+func (w *bisectWizard) launch() {
+	w.launching = true
+	w.crashed = false
+	w.mw.BisectLaunch = w
+	w.update()
+
+	w.mw.launchSelection(w.bisect.Selection(), func(err error) {
+		w.launching = false
+		w.mw.BisectLaunch = nil
+
+		// Only a launch that got as far as the game tells anything.
+		var launchErr *lib.LaunchError
+		ran := err == nil || (errors.As(err, &launchErr) && launchErr.Stage == lib.LaunchStageGame)
+		if ran && w.auto.Active() {
+			if w.crashed {
+				w.mw.toast(lib.T_("The game crashed, marked as bad"))
+			} else {
+				w.mw.toast(lib.T_("The game exited cleanly, marked as good"))
+			}
+			w.mark(w.crashed)
+			return
+		}
+		w.update()
+	})
+}
+
+func (w *bisectWizard) mark(crashed bool) {
+	b, err := w.mw.Manager.MarkBisect(crashed)
+	if err != nil {
+		w.mw.toast(err.Error())
+		return
+	}
+	w.bisect = b
+	w.update()
+}
+
+func (w *bisectWizard) skip() {
+	b, err := w.mw.Manager.SkipBisect()
+	if err != nil {
+		w.mw.toast(err.Error())
+		return
+	}
+	w.bisect = b
+	w.update()
+}
+
+func (w *bisectWizard) disableCulprits() {
+	for _, folder := range w.bisect.Culprits {
+		w.mw.setModsEnabled(folder, false)
+	}
+	w.stop()
+}
+
+func (w *bisectWizard) stop() {
+	if err := lib.ResetBisect(); err != nil {
+		w.mw.toast(err.Error())
+		return
+	}
+	w.bisect = nil
+	w.update()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"herbarium/lib"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// setupCommandLine declares the options of herbarium-gui. Command lines of
+// later invocations are forwarded by GApplication to the primary instance,
+// so `herbarium-gui --launch` acts on the window that is already open.
+func (a *HerbariumApp) setupCommandLine() {
+	a.App.AddMainOption("launch", 'l', glib.OptionFlagNone, glib.OptionArgNone,
+		lib.T_("Launch the game with the current mods"), "")
+	a.App.AddMainOption("vanilla", 0, glib.OptionFlagNone, glib.OptionArgNone,
+		lib.T_("Launch the game with all mods disabled"), "")
+	a.App.AddMainOption("profile", 'p', glib.OptionFlagNone, glib.OptionArgString,
+		lib.T_("Switch to a profile"), lib.T_("NAME"))
+	a.App.AddMainOption("show", 's', glib.OptionFlagNone, glib.OptionArgString,
+		lib.T_("Show a mod by folder or codename"), lib.T_("MOD"))
+	a.App.AddMainOption("verbose", 'v', glib.OptionFlagNone, glib.OptionArgNone,
+		lib.T_("Print debug messages to the terminal"), "")
+
+	// Logging is set up by every invocation, before the command line is
+	// handed over to the primary instance.
+	a.App.ConnectHandleLocalOptions(func(opts *glib.VariantDict) int {
+		level := slog.LevelWarn
+		if opts.Contains("verbose") {
+			level = slog.LevelDebug
+		}
+		if _, err := lib.SetupLogging(os.Stderr, level); err != nil {
+			slog.Warn(lib.T_("cannot open log file:"), "err", err)
+		}
+		return -1
+	})
+
+	a.App.ConnectCommandLine(func(cmdline *gio.ApplicationCommandLine) int {
+		return a.commandLine(cmdline)
+	})
+
+	a.App.ConnectOpen(func(files []gio.Filer, _ string) {
+		mw := a.window()
+		for _, f := range files {
+			mw.installArchive(f.Path())
+		}
+		a.Activate()
+	})
+}
+
+func (a *HerbariumApp) commandLine(cmdline *gio.ApplicationCommandLine) int {
+	a.Activate()
+	mw := a.Window
+	opts := cmdline.OptionsDict()
+	stringType := glib.NewVariantType("s")
+
+	if v := opts.LookupValue("profile", stringType); v != nil {
+		mw.switchProfile(v.String())
+	}
+
+	args := cmdline.Arguments()
+	for _, arg := range args[min(1, len(args)):] {
+		file := cmdline.CreateFileForArg(arg)
+		if !strings.EqualFold(strings.TrimPrefix(lowerExt(file.Path()), "."), "zip") {
+			cmdline.PrinterrLiteral(lib.T_("Not a mod archive:") + " " + arg + "\n")
+			continue
+		}
+		mw.installArchive(file.Path())
+	}
+
+	if v := opts.LookupValue("show", stringType); v != nil {
+		mw.showMod(v.String())
+	}
+
+	switch {
+	case opts.Contains("launch"):
+		mw.launch()
+	case opts.Contains("vanilla"):
+		mw.launchVanilla()
+	}
+	return 0
+}
+
+func lowerExt(path string) string {
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		return strings.ToLower(path[i:])
+	}
+	return ""
+}
+
+func (mw *HerbariumWindow) installArchive(path string) {
+	folder, err := lib.InstallModArchive(mw.Manager.Config, path)
+	if err != nil {
+		mw.toast(err.Error())
+		return
+	}
+
+	mw.reload(func() {
+		mw.showMod(folder)
+		mw.toast(lib.T_("Installed") + " " + folder)
+	})
+}
+
+// showMod filters the grid down to one mod and focuses its card.
+func (mw *HerbariumWindow) showMod(id string) {
+	for _, card := range mw.ModCards {
+		if !card.ModEntry.Matches(id) {
+			continue
+		}
+
+		mw.SearchBar.SetSearchMode(true)
+		mw.SearchEntry.SetText(card.ModEntry.DisplayName())
+		mw.StateDropdown.SetSelected(0)
+		mw.updateFilter()
+		card.GrabFocus()
+		return
+	}
+	mw.toast(lib.T_("mod not found:") + " " + id)
+}
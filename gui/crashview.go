@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"herbarium/lib"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// showCrash tells that the game crashed, with the mods found in the
+// traceback, and offers to disable the most likely one.
+func (mw *HerbariumWindow) showCrash(r *lib.CrashReport) {
+	dialog := adw.NewAlertDialog(lib.T_("The game crashed"), r.Summary())
+
+	var details strings.Builder
+	if r.Error != "" {
+		details.WriteString(r.Error + "\n\n")
+	}
+	for _, s := range r.Suspects {
+		fmt.Fprintf(&details, "%s  %s\n    %s:%s\n", s.Folder, s.Name, s.File, s.Line)
+	}
+	if r.File != "" {
+		details.WriteString("\n" + lib.T_("traceback:") + " " + r.File + "\n")
+	}
+
+	if details.Len() > 0 {
+		label := gtk.NewLabel(strings.TrimSpace(details.String()))
+		label.SetSelectable(true)
+		label.SetWrap(true)
+		label.SetXAlign(0)
+		label.AddCSSClass("monospace")
+
+		scroll := gtk.NewScrolledWindow()
+		scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+		scroll.SetMinContentHeight(120)
+		scroll.SetChild(label)
+		dialog.SetExtraChild(scroll)
+	}
+
+	dialog.AddResponse("close", lib.T_("Close"))
+	dialog.SetCloseResponse("close")
+	dialog.SetDefaultResponse("close")
+	if len(r.Suspects) > 0 {
+		suspect := r.Suspects[0]
+		dialog.AddResponse("disable", lib.Format(lib.T_("Disable {name}"), lib.Args{"name": suspect.Name}))
+		dialog.SetResponseAppearance("disable", adw.ResponseDestructive)
+		dialog.ConnectResponse(func(response string) {
+			if response == "disable" {
+				mw.setModsEnabled(suspect.Folder, false)
+			}
+		})
+	}
+	dialog.Present(mw.Window)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"herbarium/lib"
+	"strings"
+
+	gcore "github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+const textDomain = "herbarium"
+
+// glibTranslator translates through GLib's gettext, the same catalog GTK
+// and the desktop file use.
+type glibTranslator struct{}
+
+func initLocales() {
+	gcore.InitI18n(textDomain, lib.LocaleDir)
+	lib.SetTranslator(glibTranslator{})
+}
+
+func (glibTranslator) Gettext(msgid string) string {
+	return gcore.Local(msgid)
+}
+
+func (glibTranslator) NGettext(msgid, plural string, n int) string {
+	return glib.Dngettext(textDomain, msgid, plural, uint32(max(n, 0)))
+}
+
+func (glibTranslator) PGettext(context, msgid string) string {
+	return glib.Dpgettext2(textDomain, context, msgid)
+}
+
+// NPGettext looks up "context\x04msgid" the way gettext stores contexts,
+// as GLib has no plural variant with context.
+func (glibTranslator) NPGettext(context, msgid, plural string, n int) string {
+	key := context + "\x04" + msgid
+	t := glib.Dngettext(textDomain, key, plural, uint32(max(n, 0)))
+	if t == key {
+		return msgid
+	}
+	return strings.TrimPrefix(t, context+"\x04")
+}
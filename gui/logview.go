@@ -0,0 +1,65 @@
+package main
+
+import (
+	"herbarium/lib"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// showLog opens a page with the log file, for troubleshooting failed
+// launches and restores.
+func (mw *HerbariumWindow) showLog() {
+	buffer := gtk.NewTextBuffer(nil)
+	view := gtk.NewTextViewWithBuffer(buffer)
+	view.SetEditable(false)
+	view.SetMonospace(true)
+	view.SetWrapMode(gtk.WrapWordChar)
+	view.SetLeftMargin(12)
+	view.SetRightMargin(12)
+	view.SetTopMargin(12)
+	view.SetBottomMargin(12)
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetVExpand(true)
+	scroll.SetChild(view)
+
+	load := func() {
+		text, err := lib.ReadLog()
+		if err != nil {
+			text = err.Error()
+		}
+		if text == "" {
+			text = lib.T_("The log is empty.")
+		}
+		buffer.SetText(text)
+		view.ScrollToMark(buffer.CreateMark("", buffer.EndIter(), false), 0, false, 0, 0)
+	}
+
+	refresh := gtk.NewButtonFromIconName("view-refresh-symbolic")
+	refresh.SetTooltipText(lib.T_("Reload"))
+	refresh.ConnectClicked(load)
+
+	copyBtn := gtk.NewButtonFromIconName("edit-copy-symbolic")
+	copyBtn.SetTooltipText(lib.T_("Copy to clipboard"))
+	copyBtn.ConnectClicked(func() {
+		mw.Window.Clipboard().SetText(buffer.Text(buffer.StartIter(), buffer.EndIter(), false))
+		mw.toast(lib.T_("Log copied to clipboard"))
+	})
+
+	header := adw.NewHeaderBar()
+	header.PackEnd(copyBtn)
+	header.PackEnd(refresh)
+
+	if path, err := lib.LogPath(); err == nil {
+		title := adw.NewWindowTitle(lib.T_("Log"), path)
+		header.SetTitleWidget(title)
+	}
+
+	toolbar := adw.NewToolbarView()
+	toolbar.AddTopBar(header)
+	toolbar.SetContent(scroll)
+
+	load()
+	mw.NavView.Push(adw.NewNavigationPage(toolbar, lib.T_("Log")))
+}
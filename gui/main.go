@@ -2,6 +2,7 @@ package main
 
 import (
 	"herbarium/lib"
+	"log/slog"
 	"os"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
@@ -12,6 +13,7 @@ import (
 type HerbariumApp struct {
 	XDGName string
 	App     *adw.Application
+	Window  *HerbariumWindow
 }
 
 var appInstance *HerbariumApp
@@ -19,7 +21,7 @@ var appInstance *HerbariumApp
 func GetHerbariumApp() *HerbariumApp {
 	if appInstance == nil {
 		xdgName := "ru.ximper.Herbarium"
-		adwApp := adw.NewApplication(xdgName, gio.ApplicationFlagsNone)
+		adwApp := adw.NewApplication(xdgName, gio.ApplicationHandlesCommandLine|gio.ApplicationHandlesOpen)
 
 		appInstance = &HerbariumApp{
 			XDGName: xdgName,
@@ -30,16 +32,24 @@ func GetHerbariumApp() *HerbariumApp {
 }
 
 func (a *HerbariumApp) Activate() {
-	mw := NewHerbariumWindow(a)
-	mw.Window.Present()
+	if a.Window == nil {
+		a.Window = NewHerbariumWindow(a)
+	}
+	a.Window.Window.Present()
 }
 
 func main() {
-	lib.InitLocales()
+	initLocales()
 
 	glib.LogSetDebugEnabled(false)
 
+	if err := lib.SelectGame(os.Getenv("HERBARIUM_GAME")); err != nil {
+		slog.Error(err.Error())
+	}
+
 	app := GetHerbariumApp()
+	app.setupActions()
+	app.setupCommandLine()
 
 	app.App.ConnectActivate(func() {
 		app.Activate()
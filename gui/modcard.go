@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"herbarium/lib"
+	"log/slog"
 	"os"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
@@ -12,41 +13,59 @@ import (
 	"github.com/diamondburned/gotk4/pkg/pango"
 )
 
+// Card widths, in pixels, for normal and narrow windows.
+const (
+	cardSize        = 200
+	compactCardSize = 150
+)
+
 type ModCard struct {
 	*gtk.FlowBoxChild
 	ModEntry  *lib.ModEntry
 	CheckBtn  *gtk.CheckButton
 	Label     *gtk.Label
+	NameStack *gtk.Stack
+	NameEntry *gtk.Entry
 	Container *gtk.Box
 	Picture   *gtk.Picture
 	Video     *gtk.Video
+	Badges    *gtk.Box
+
+	box     *gtk.Box
+	overlay *gtk.Overlay
+	clamp   *adw.Clamp
+	menu    *gio.Menu
+	size    int
+
+	// coverChanged, if set, is called after the cover was replaced.
+	coverChanged func()
+
+	app            *HerbariumApp
+	manager        *lib.Manager
+	toggledHandler glib.SignalHandle
 }
 
 func NewModCard(
 	app *HerbariumApp,
+	manager *lib.Manager,
 	mod *lib.ModEntry,
-	cfgPath, dbPath string,
 	onToggle func(),
 ) *ModCard {
 	vbox := gtk.NewBox(gtk.OrientationVertical, 4)
 	vbox.SetSpacing(16)
 	vbox.SetHAlign(gtk.AlignCenter)
 	vbox.SetVAlign(gtk.AlignStart)
-	vbox.SetSizeRequest(200, -1)
 
 	imageOverlay := gtk.NewOverlay()
 	imageOverlay.SetVAlign(gtk.AlignCenter)
 	imageOverlay.SetHAlign(gtk.AlignCenter)
-	imageOverlay.SetSizeRequest(200, 200)
 
 	container := gtk.NewBox(gtk.OrientationHorizontal, 0)
 	container.SetHAlign(gtk.AlignCenter)
 	container.SetVAlign(gtk.AlignCenter)
-	container.SetSizeRequest(200, 200)
 	imageOverlay.SetChild(container)
 
 	picture := gtk.NewPicture()
-	picture.SetSizeRequest(200, 200)
 	picture.SetContentFit(gtk.ContentFitFill)
 	container.Append(picture)
 
@@ -57,9 +76,19 @@ func NewModCard(
 	check.SetMarginEnd(4)
 	check.SetMarginTop(4)
 	check.SetActive(mod.Enabled)
+	// The card itself takes the focus; Space toggles it, see setupKeys.
+	check.SetCanFocus(false)
 	imageOverlay.AddOverlay(check)
 
-	label := gtk.NewLabel(mod.Name)
+	badges := gtk.NewBox(gtk.OrientationVertical, 4)
+	badges.SetHAlign(gtk.AlignStart)
+	badges.SetVAlign(gtk.AlignEnd)
+	badges.SetMarginStart(6)
+	badges.SetMarginBottom(6)
+	badges.SetCanTarget(false)
+	imageOverlay.AddOverlay(badges)
+
+	label := gtk.NewLabel(mod.DisplayName())
 	label.AddCSSClass("heading")
 	label.AddCSSClass("title-2")
 	label.SetHExpand(false)
@@ -68,16 +97,28 @@ func NewModCard(
 	label.SetWrap(true)
 	label.SetWrapMode(pango.WrapWordChar)
 
+	entry := gtk.NewEntry()
+	entry.SetAlignment(0.5)
+
+	nameStack := gtk.NewStack()
+	nameStack.SetVhomogeneous(false)
+	nameStack.AddNamed(label, "label")
+	nameStack.AddNamed(entry, "entry")
+
 	vbox.Append(imageOverlay)
-	vbox.Append(label)
+	vbox.Append(nameStack)
 
-	check.ConnectToggled(func() {
+	var toggledHandler glib.SignalHandle
+	toggledHandler = check.ConnectToggled(func() {
 		enabled := check.Active()
-		oldEnabled := mod.Enabled
-		mod.Enabled = enabled
-		if err := lib.ToggleEnabled(enabled, mod.Folder); err != nil {
-			mod.Enabled = oldEnabled
-			check.SetActive(oldEnabled)
+		if err := manager.SetEnabled(mod.Folder, enabled); err != nil {
+			slog.Error(err.Error())
+			app.Window.toast(err.Error())
+			// The manager kept the old value; show it again without
+			// writing it back.
+			check.HandlerBlock(toggledHandler)
+			check.SetActive(!enabled)
+			check.HandlerUnblock(toggledHandler)
 			return
 		}
 		if onToggle != nil {
@@ -95,7 +136,6 @@ func NewModCard(
 	clamp := adw.NewClamp()
 	clamp.SetOverflow(gtk.OverflowHidden)
 	clamp.AddCSSClass("card")
-	clamp.SetMaximumSize(200)
 	clamp.SetChild(vbox)
 	child.SetChild(clamp)
 
@@ -104,17 +144,88 @@ func NewModCard(
 		ModEntry:     mod,
 		CheckBtn:     check,
 		Label:        label,
+		NameStack:    nameStack,
+		NameEntry:    entry,
 		Container:    container,
 		Picture:      picture,
+		Badges:       badges,
+
+		box:     vbox,
+		overlay: imageOverlay,
+		clamp:   clamp,
+
+		app:            app,
+		manager:        manager,
+		toggledHandler: toggledHandler,
 	}
 
+	card.SetSize(cardSize)
+	card.setupEditing()
+	card.setupKeys()
+	card.UpdateBadges()
 	go card.GetPoster(app)
 
 	return card
 }
 
+// SetSize sets the width of the card and of its square cover.
+func (card *ModCard) SetSize(size int) {
+	card.size = size
+	card.box.SetSizeRequest(size, -1)
+	card.overlay.SetSizeRequest(size, size)
+	card.Container.SetSizeRequest(size, size)
+	card.Picture.SetSizeRequest(size, size)
+	if card.Video != nil {
+		card.Video.SetSizeRequest(size, size)
+	}
+	card.clamp.SetMaximumSize(size)
+}
+
+// SetEnabled updates the card after the mod was changed elsewhere, without
+// writing it back to the database.
+func (card *ModCard) SetEnabled(enabled bool) {
+	card.ModEntry.Enabled = enabled
+	card.CheckBtn.HandlerBlock(card.toggledHandler)
+	card.CheckBtn.SetActive(enabled)
+	card.CheckBtn.HandlerUnblock(card.toggledHandler)
+}
+
+func (card *ModCard) UpdateBadges() {
+	for child := card.Badges.FirstChild(); child != nil; child = card.Badges.FirstChild() {
+		card.Badges.Remove(child)
+	}
+
+	mod := card.ModEntry
+	if mod.NeedsUpdate {
+		card.addBadge(lib.T_("Update pending"), "warning")
+	}
+	if mod.Unsubscribed {
+		card.addBadge(lib.T_("Not subscribed"), "dim-label")
+	}
+	if mod.Size > 0 {
+		card.addBadge(lib.FormatSize(mod.Size), "")
+	}
+
+	if !mod.UpdatedAt.IsZero() {
+		card.SetTooltipText(lib.T_("Last updated:") + " " +
+			mod.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+func (card *ModCard) addBadge(text, class string) {
+	badge := gtk.NewLabel(text)
+	badge.SetHAlign(gtk.AlignStart)
+	badge.AddCSSClass("osd")
+	badge.AddCSSClass("caption")
+	if class != "" {
+		badge.AddCSSClass(class)
+	}
+	badge.SetMarginTop(2)
+	card.Badges.Append(badge)
+}
+
 func (card *ModCard) GetPoster(app *HerbariumApp) bool {
-	path, err := lib.GetOrDownloadCover(app.XDGName, *card.ModEntry)
+	path, err := lib.GetOrDownloadCover(card.manager.Config, app.XDGName, *card.ModEntry)
 	if err != nil {
 		return true
 	}
@@ -131,11 +242,17 @@ func (card *ModCard) GetPoster(app *HerbariumApp) bool {
 		bytes.HasPrefix(buffer, []byte("GIF87a"))
 
 	glib.IdleAdd(func() {
+		if card.Video != nil {
+			card.Container.Remove(card.Video)
+			card.Container.Append(card.Picture)
+			card.Video = nil
+		}
+
 		if isGIF {
 			card.Container.Remove(card.Picture)
 
 			video := gtk.NewVideo()
-			video.SetSizeRequest(200, 200)
+			video.SetSizeRequest(card.size, card.size)
 			video.SetLoop(true)
 			video.SetAutoplay(true)
 			video.SetCanFocus(false)
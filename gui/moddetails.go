@@ -0,0 +1,165 @@
+package main
+
+import (
+	"herbarium/lib"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// setupKeys makes the card usable without a mouse once the grid moved the
+// focus to it with the arrow keys: Space toggles the mod, Enter opens its
+// details, F2 renames it and the Menu key opens the context menu.
+func (card *ModCard) setupKeys() {
+	keys := gtk.NewEventControllerKey()
+	keys.ConnectKeyPressed(func(keyval, _ uint, state gdk.ModifierType) bool {
+		// Keys typed into the name entry are its own, and the ones with
+		// Ctrl or Alt belong to the window shortcuts.
+		if card.NameStack.VisibleChildName() == "entry" ||
+			state&(gdk.ControlMask|gdk.AltMask) != 0 {
+			return false
+		}
+
+		switch keyval {
+		case gdk.KEY_space, gdk.KEY_KP_Space:
+			card.CheckBtn.SetActive(!card.CheckBtn.Active())
+		case gdk.KEY_Return, gdk.KEY_KP_Enter, gdk.KEY_ISO_Enter:
+			card.app.Window.showModDetails(card)
+		case gdk.KEY_F2:
+			card.startRename()
+		case gdk.KEY_Menu:
+			card.showMenu(card.Width()/2, card.Height()/2)
+		case gdk.KEY_F10:
+			if state&gdk.ShiftMask == 0 {
+				return false
+			}
+			card.showMenu(card.Width()/2, card.Height()/2)
+		default:
+			return false
+		}
+		return true
+	})
+	card.AddController(keys)
+}
+
+// showModDetails opens a page with everything known about a mod and the
+// edits the card offers, laid out as rows that the keyboard can reach.
+// Leaving it gives the focus back to the card.
+func (mw *HerbariumWindow) showModDetails(card *ModCard) {
+	mod := card.ModEntry
+
+	cover := gtk.NewPicture()
+	cover.SetContentFit(gtk.ContentFitContain)
+	cover.SetSizeRequest(-1, 240)
+	cover.AddCSSClass("card")
+	cover.SetOverflow(gtk.OverflowHidden)
+	loadCover := func() {
+		go func() {
+			path, err := lib.GetOrDownloadCover(mw.Manager.Config, mw.App.XDGName, *mod)
+			if err != nil {
+				return
+			}
+			glib.IdleAdd(func() { cover.SetFilename(path) })
+		}()
+	}
+	loadCover()
+
+	name := adw.NewEntryRow()
+	name.SetTitle(lib.T_("Name"))
+	name.SetText(mod.DisplayName())
+	name.SetShowApplyButton(true)
+
+	enabled := adw.NewSwitchRow()
+	enabled.SetTitle(lib.T_("Enabled"))
+	enabled.SetActive(mod.Enabled)
+	enabled.NotifyProperty("active", func() {
+		if enabled.Active() != card.CheckBtn.Active() {
+			card.CheckBtn.SetActive(enabled.Active())
+			// The card goes back if the change could not be saved.
+			enabled.SetActive(card.CheckBtn.Active())
+		}
+	})
+
+	info := adw.NewPreferencesGroup()
+	info.Add(name)
+	info.Add(enabled)
+	addInfo := func(title, value string) {
+		if value == "" {
+			return
+		}
+		row := adw.NewActionRow()
+		row.SetTitle(title)
+		row.SetSubtitle(value)
+		row.SetSubtitleSelectable(true)
+		row.AddCSSClass("property")
+		info.Add(row)
+	}
+	addInfo(lib.T_("Codename"), mod.CodeName)
+	addInfo(lib.T_("Folder"), mod.Folder)
+	if mod.Size > 0 {
+		addInfo(lib.T_("Size"), lib.FormatSize(mod.Size))
+	}
+	if !mod.UpdatedAt.IsZero() {
+		addInfo(lib.T_("Last updated"), mod.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if !mod.DiscoveredAt.IsZero() {
+		addInfo(lib.T_("Added"), mod.DiscoveredAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	covers := adw.NewPreferencesGroup()
+	covers.SetTitle(lib.T_("Cover"))
+	addButton := func(title string, activate func()) {
+		row := adw.NewActionRow()
+		row.SetTitle(title)
+		row.SetActivatable(true)
+		row.AddSuffix(gtk.NewImageFromIconName("go-next-symbolic"))
+		row.ConnectActivated(activate)
+		covers.Add(row)
+	}
+	addButton(lib.T_("Choose cover…"), card.chooseCover)
+	addButton(lib.T_("Reset cover"), func() { card.setCover("") })
+
+	box := gtk.NewBox(gtk.OrientationVertical, 24)
+	box.SetMarginTop(24)
+	box.SetMarginBottom(24)
+	box.SetMarginStart(12)
+	box.SetMarginEnd(12)
+	box.Append(cover)
+	box.Append(info)
+	box.Append(covers)
+
+	clamp := adw.NewClamp()
+	clamp.SetMaximumSize(480)
+	clamp.SetChild(box)
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetVExpand(true)
+	scroll.SetChild(clamp)
+
+	toolbar := adw.NewToolbarView()
+	toolbar.AddTopBar(adw.NewHeaderBar())
+	toolbar.SetContent(scroll)
+
+	page := adw.NewNavigationPage(toolbar, mod.DisplayName())
+
+	name.ConnectApply(func() {
+		if err := mw.Manager.Rename(mod.Folder, name.Text()); err != nil {
+			mw.toast(err.Error())
+			return
+		}
+		card.Label.SetText(mod.DisplayName())
+		name.SetText(mod.DisplayName())
+		page.SetTitle(mod.DisplayName())
+	})
+
+	card.coverChanged = loadCover
+	page.ConnectHidden(func() {
+		card.coverChanged = nil
+		card.GrabFocus()
+	})
+
+	mw.NavView.Push(page)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"herbarium/lib"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// setupEditing lets the name be edited in place with a double click, and
+// adds a context menu for renaming and replacing the cover. The changes are
+// stored as overrides, so rescans keep them.
+func (card *ModCard) setupEditing() {
+	doubleClick := gtk.NewGestureClick()
+	doubleClick.ConnectPressed(func(n int, _, _ float64) {
+		if n == 2 {
+			card.startRename()
+		}
+	})
+	card.Label.AddController(doubleClick)
+
+	card.NameEntry.ConnectActivate(func() {
+		card.finishRename(true)
+	})
+
+	keys := gtk.NewEventControllerKey()
+	keys.ConnectKeyPressed(func(keyval, _ uint, _ gdk.ModifierType) bool {
+		if keyval == gdk.KEY_Escape {
+			card.finishRename(false)
+			return true
+		}
+		return false
+	})
+	card.NameEntry.AddController(keys)
+
+	focus := gtk.NewEventControllerFocus()
+	focus.ConnectLeave(func() {
+		card.finishRename(true)
+	})
+	card.NameEntry.AddController(focus)
+
+	group := gio.NewSimpleActionGroup()
+	addAction := func(name string, activate func()) {
+		action := gio.NewSimpleAction(name, nil)
+		action.ConnectActivate(func(_ *glib.Variant) {
+			activate()
+		})
+		group.AddAction(action)
+	}
+	addAction("rename", card.startRename)
+	addAction("set-cover", card.chooseCover)
+	addAction("reset-cover", func() {
+		card.setCover("")
+	})
+	card.InsertActionGroup("card", group)
+
+	card.menu = gio.NewMenu()
+	card.menu.Append(lib.T_("Rename"), "card.rename")
+	card.menu.Append(lib.T_("Choose cover…"), "card.set-cover")
+	card.menu.Append(lib.T_("Reset cover"), "card.reset-cover")
+
+	rightClick := gtk.NewGestureClick()
+	rightClick.SetButton(gdk.BUTTON_SECONDARY)
+	rightClick.ConnectPressed(func(_ int, x, y float64) {
+		card.showMenu(int(x), int(y))
+	})
+	card.AddController(rightClick)
+}
+
+// showMenu opens the context menu pointing at x, y in the card.
+func (card *ModCard) showMenu(x, y int) {
+	// The popover is made each time and dropped when closed, so that cards
+	// thrown away on reload hold no children.
+	popover := gtk.NewPopoverMenuFromModel(card.menu)
+	popover.SetParent(card)
+	rect := gdk.NewRectangle(x, y, 1, 1)
+	popover.SetPointingTo(&rect)
+	popover.ConnectClosed(func() {
+		glib.IdleAdd(popover.Unparent)
+	})
+	popover.Popup()
+}
+
+func (card *ModCard) startRename() {
+	card.NameEntry.SetText(card.ModEntry.DisplayName())
+	card.NameStack.SetVisibleChildName("entry")
+	card.NameEntry.GrabFocus()
+}
+
+// finishRename leaves the entry, saving its text unless commit is false.
+// An empty name goes back to the extracted one.
+func (card *ModCard) finishRename(commit bool) {
+	if card.NameStack.VisibleChildName() != "entry" {
+		return
+	}
+	card.NameStack.SetVisibleChildName("label")
+	if !commit || card.NameEntry.Text() == card.ModEntry.DisplayName() {
+		return
+	}
+
+	if err := card.manager.Rename(card.ModEntry.Folder, card.NameEntry.Text()); err != nil {
+		card.app.Window.toast(err.Error())
+		return
+	}
+	card.Label.SetText(card.ModEntry.DisplayName())
+}
+
+func (card *ModCard) chooseCover() {
+	filter := gtk.NewFileFilter()
+	filter.SetName(lib.T_("Images"))
+	filter.AddPixbufFormats()
+
+	dialog := gtk.NewFileDialog()
+	dialog.SetTitle(lib.T_("Choose cover"))
+	dialog.SetDefaultFilter(filter)
+	dialog.Open(context.Background(), &card.app.Window.Window.Window, func(res gio.AsyncResulter) {
+		file, err := dialog.OpenFinish(res)
+		if err != nil || file == nil {
+			return
+		}
+		card.setCover(file.Path())
+	})
+}
+
+// setCover stores image as the cover override, or removes the override
+// when it is empty, and shows the result.
+func (card *ModCard) setCover(image string) {
+	if err := card.manager.SetCover(card.ModEntry.Folder, image); err != nil {
+		card.app.Window.toast(err.Error())
+		return
+	}
+	go card.GetPoster(card.app)
+	if card.coverChanged != nil {
+		card.coverChanged()
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"herbarium/lib"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// scanPageDelay keeps the progress page away for quick scans, which are
+// the usual case once the library is known.
+const scanPageDelay = 300
+
+// setupScanPage builds the page shown instead of the cards while new
+// folders are scanned.
+func (mw *HerbariumWindow) setupScanPage() {
+	mw.ScanPage.SetIconName("system-search-symbolic")
+	mw.ScanPage.SetTitle(lib.T_("Scanning mods"))
+
+	mw.ScanProgress.SetShowText(true)
+
+	cancel := gtk.NewButtonWithLabel(lib.T_("Cancel"))
+	cancel.AddCSSClass("pill")
+	cancel.SetHAlign(gtk.AlignCenter)
+	cancel.ConnectClicked(func() {
+		if mw.CancelScan != nil {
+			mw.CancelScan()
+		}
+	})
+
+	box := gtk.NewBox(gtk.OrientationVertical, 24)
+	box.Append(mw.ScanProgress)
+	box.Append(cancel)
+
+	clamp := adw.NewClamp()
+	clamp.SetMaximumSize(360)
+	clamp.SetChild(box)
+	mw.ScanPage.SetChild(clamp)
+}
+
+// scan brings the library up to date off the main thread and rebuilds the
+// cards, then calls done if it is not nil. While a scan runs, another one
+// is not started and done waits for the running one.
+func (mw *HerbariumWindow) scan(done func()) {
+	if done != nil {
+		mw.ScanDone = append(mw.ScanDone, done)
+	}
+	if mw.CancelScan != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	mw.CancelScan = cancel
+
+	mw.ScanProgress.SetFraction(0)
+	mw.ScanProgress.SetText("")
+	mw.ScanPage.SetDescription("")
+	glib.TimeoutAdd(scanPageDelay, func() bool {
+		if mw.CancelScan != nil {
+			mw.ContentStack.SetVisibleChildName("scan")
+		}
+		return false
+	})
+
+	go func() {
+		err := mw.Manager.Scan(ctx, false)
+		glib.IdleAdd(func() {
+			cancel()
+			mw.CancelScan = nil
+			mw.ContentStack.SetVisibleChildName("mods")
+
+			switch {
+			case errors.Is(err, context.Canceled):
+				mw.toast(lib.T_("Scan cancelled, new folders were not added"))
+			case err != nil:
+				mw.toast(err.Error())
+			}
+
+			mw.loadMods(mw.App)
+			mw.updateFilter()
+			pending := mw.ScanDone
+			mw.ScanDone = nil
+			for _, done := range pending {
+				done()
+			}
+		})
+	}()
+}
+
+func (mw *HerbariumWindow) showScanProgress(p lib.ScanProgress) {
+	switch p.Phase {
+	case lib.ScanReading:
+		mw.ScanPage.SetDescription(lib.T_("Reading the Workshop folder…"))
+	case lib.ScanExtracting:
+		mw.ScanPage.SetDescription(p.Folder)
+		mw.ScanProgress.SetFraction(float64(p.Index) / float64(p.Total))
+		mw.ScanProgress.SetText(lib.Format(lib.T_("{index} of {total}"), lib.Args{"index": p.Index, "total": p.Total}))
+	case lib.ScanWorkshop:
+		mw.ScanPage.SetDescription(lib.T_("Reading the Steam download state…"))
+		mw.ScanProgress.SetFraction(1)
+	case lib.ScanHashing:
+		mw.ScanPage.SetDescription(lib.Format(lib.T_("Recording the files of {folder}"), lib.Args{"folder": p.Folder}))
+		mw.ScanProgress.SetFraction(float64(p.Index) / float64(p.Total))
+		mw.ScanProgress.SetText(lib.Format(lib.T_("{index} of {total}"), lib.Args{"index": p.Index, "total": p.Total}))
+	}
+}
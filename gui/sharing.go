@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"herbarium/lib"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func (mw *HerbariumWindow) exportModList() {
+	list, err := lib.ExportModList(mw.Manager.DB, "")
+	if err != nil {
+		mw.toast(err.Error())
+		return
+	}
+
+	dialog := gtk.NewFileDialog()
+	dialog.SetTitle(lib.T_("Export mod list"))
+	dialog.SetInitialName("herbarium-mods.yaml")
+	dialog.Save(context.Background(), &mw.Window.Window, func(res gio.AsyncResulter) {
+		file, err := dialog.SaveFinish(res)
+		if err != nil || file == nil {
+			return
+		}
+
+		if err := lib.WriteModList(list, file.Path()); err != nil {
+			mw.toast(err.Error())
+			return
+		}
+
+		mw.Window.Clipboard().SetText(lib.EncodeModList(list))
+		mw.toast(lib.T_("Mod list saved and copied to clipboard"))
+	})
+}
+
+func (mw *HerbariumWindow) importModList() {
+	dialog := gtk.NewFileDialog()
+	dialog.SetTitle(lib.T_("Import mod list"))
+	dialog.Open(context.Background(), &mw.Window.Window, func(res gio.AsyncResulter) {
+		file, err := dialog.OpenFinish(res)
+		if err != nil || file == nil {
+			return
+		}
+		mw.applyModList(file.Path())
+	})
+}
+
+func (mw *HerbariumWindow) importFromClipboard() {
+	clipboard := mw.Window.Clipboard()
+	clipboard.ReadTextAsync(context.Background(), func(res gio.AsyncResulter) {
+		text, err := clipboard.ReadTextFinish(res)
+		if err != nil || !strings.HasPrefix(strings.TrimSpace(text), "herbarium:") {
+			mw.toast(lib.T_("Clipboard does not contain a mod list"))
+			return
+		}
+		mw.applyModList(text)
+	})
+}
+
+func (mw *HerbariumWindow) applyModList(src string) {
+	list, err := lib.ReadModList(src)
+	if err != nil {
+		mw.toast(err.Error())
+		return
+	}
+
+	res, err := lib.ImportModList(mw.Manager.DB, list, "")
+	if err != nil {
+		mw.toast(err.Error())
+		return
+	}
+
+	if err := mw.Manager.Save(); err != nil {
+		mw.toast(err.Error())
+		return
+	}
+
+	mw.syncCards(mw.Manager.DB)
+
+	if len(res.Missing) == 0 {
+		mw.toast(appliedText(res, list))
+		return
+	}
+
+	var body strings.Builder
+	for _, m := range res.Missing {
+		name := m.Name
+		if name == "" {
+			name = m.ID
+		}
+		url := lib.WorkshopURL(m.ID)
+		fmt.Fprintf(&body, "<a href=\"%s\">%s</a>\n", url, glib.MarkupEscapeText(name))
+	}
+
+	dialog := adw.NewAlertDialog(
+		appliedText(res, list),
+		lib.T_("These mods are not installed. Subscribe to them in the Steam Workshop:"),
+	)
+	label := gtk.NewLabel(body.String())
+	label.SetUseMarkup(true)
+	label.SetSelectable(true)
+	label.SetWrap(true)
+	dialog.SetExtraChild(label)
+	dialog.AddResponse("close", lib.T_("Close"))
+	dialog.Present(mw.Window)
+}
+
+func appliedText(res *lib.ImportResult, list *lib.ModList) string {
+	return lib.Format(
+		lib.N_("Applied {applied} of {total} mod", "Applied {applied} of {total} mods", len(list.Mods)),
+		lib.Args{"applied": len(res.Applied), "total": len(list.Mods)})
+}
+
+// syncCards updates the cards from a database changed outside of them.
+func (mw *HerbariumWindow) syncCards(db *lib.ModsDB) {
+	for _, m := range db.Mods {
+		if card := mw.ModCards[m.Folder]; card != nil {
+			card.SetEnabled(m.Enabled)
+		}
+	}
+	mw.updateFilter()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"herbarium/lib"
 	"fmt"
 	"sort"
@@ -9,12 +10,14 @@ import (
 	"unsafe"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 )
 
 type HerbariumWindow struct {
 	Window             *adw.ApplicationWindow
+	ToastOverlay       *adw.ToastOverlay
 	NavView            *adw.NavigationView
 	ToolbarView        *adw.ToolbarView
 	Header             *adw.HeaderBar
@@ -31,44 +34,58 @@ type HerbariumWindow struct {
 	Spinner            *gtk.Spinner
 	SearchBar          *gtk.SearchBar
 	SearchToggle       *gtk.ToggleButton
+	MenuButton         *gtk.MenuButton
 	SearchClamp        *adw.Clamp
 	SearchBox          *gtk.Box
 	StateList          *gtk.StringList
 	TimeList           *gtk.StringList
 	ButtonBox          *gtk.Box
 	BottomBox          *gtk.Box
+	ContentStack       *gtk.Stack
+	ScanPage           *adw.StatusPage
+	ScanProgress       *gtk.ProgressBar
+	CancelScan         context.CancelFunc
+	ScanDone           []func()
 	ModCards           map[string]*ModCard
 	AllModIndices      []string
 	FilteredModIndices []string
 	FilterTimeout      glib.SourceHandle
 	PendingFilter      bool
-	CfgPath, DbPath    string
+	Manager            *lib.Manager
+	App                *HerbariumApp
+	Launching          bool
+	BisectLaunch       *bisectWizard
+	CardSize           int
 }
 
 func NewHerbariumWindow(app *HerbariumApp) *HerbariumWindow {
 	win := adw.NewApplicationWindow((*gtk.Application)(unsafe.Pointer(app.App)))
 	win.SetTitle(lib.T_("Herbarium"))
-	win.SetDefaultSize(1200, 900)
+	win.SetDefaultSize(1200, 800)
+	win.SetSizeRequest(360, 400)
 
 	mw := &HerbariumWindow{
 		Window:             win,
 		ModCards:           make(map[string]*ModCard),
 		AllModIndices:      make([]string, 0),
 		FilteredModIndices: make([]string, 0),
-		CfgPath:            "config.yaml",
-		DbPath:             "mods_db.yaml",
+		App:                app,
+		CardSize:           cardSize,
 	}
 
 	mw.createWidgets()
 	mw.setupUI()
+	mw.openManager()
 	mw.loadMods(app)
 	mw.connectSignals(app)
 	mw.updateFilter()
+	mw.scan(nil)
 
 	return mw
 }
 
 func (mw *HerbariumWindow) createWidgets() {
+	mw.ToastOverlay = adw.NewToastOverlay()
 	mw.NavView = adw.NewNavigationView()
 	mw.ToolbarView = adw.NewToolbarView()
 	mw.Header = adw.NewHeaderBar()
@@ -81,14 +98,18 @@ func (mw *HerbariumWindow) createWidgets() {
 	mw.TimeDropdown = gtk.NewDropDown(nil, nil)
 	mw.SelectAllBtn = gtk.NewButton()
 	mw.DeselectAllBtn = gtk.NewButton()
-	mw.LaunchButton = gtk.NewButtonWithLabel(lib.T_("Launch Everlasting Summer"))
+	mw.LaunchButton = gtk.NewButtonWithLabel(launchLabel())
 	mw.Spinner = gtk.NewSpinner()
 	mw.SearchBar = gtk.NewSearchBar()
 	mw.SearchToggle = gtk.NewToggleButton()
+	mw.MenuButton = gtk.NewMenuButton()
 	mw.SearchClamp = adw.NewClamp()
 	mw.SearchBox = gtk.NewBox(gtk.OrientationHorizontal, 0)
 	mw.ButtonBox = gtk.NewBox(gtk.OrientationHorizontal, 8)
 	mw.BottomBox = gtk.NewBox(gtk.OrientationHorizontal, 0)
+	mw.ContentStack = gtk.NewStack()
+	mw.ScanPage = adw.NewStatusPage()
+	mw.ScanProgress = gtk.NewProgressBar()
 
 	stateItems := []string{
 		lib.T_("All states"),
@@ -112,7 +133,8 @@ func (mw *HerbariumWindow) createWidgets() {
 }
 
 func (mw *HerbariumWindow) setupUI() {
-	mw.Window.SetContent(mw.NavView)
+	mw.ToastOverlay.SetChild(mw.NavView)
+	mw.Window.SetContent(mw.ToastOverlay)
 
 	page := adw.NewNavigationPage(mw.ToolbarView, lib.T_("Herbarium"))
 	mw.NavView.Add(page)
@@ -123,6 +145,7 @@ func (mw *HerbariumWindow) setupUI() {
 	mw.MainBox.SetHExpand(true)
 	mw.MainBox.SetVExpand(true)
 
+	mw.setupMenu()
 	mw.setupSearchBar()
 	mw.setupFlowBox()
 	mw.setupBottomBar()
@@ -134,7 +157,70 @@ func (mw *HerbariumWindow) setupUI() {
 
 	mw.MainBox.Append(mw.SearchBar)
 	mw.MainBox.Append(mw.ScrolledWindow)
-	mw.ToolbarView.SetContent(mw.MainBox)
+
+	mw.setupScanPage()
+	mw.ContentStack.AddNamed(mw.MainBox, "mods")
+	mw.ContentStack.AddNamed(mw.ScanPage, "scan")
+	mw.ContentStack.SetTransitionType(gtk.StackTransitionTypeCrossfade)
+	mw.ToolbarView.SetContent(mw.ContentStack)
+
+	mw.setupBreakpoints()
+	mw.setupShortcuts()
+}
+
+// setupBreakpoints adapts the window to narrow screens, such as phones and
+// handhelds: the search controls stack up, the stats are hidden and the
+// cards get smaller so that at least two fit in a row.
+func (mw *HerbariumWindow) setupBreakpoints() {
+	bp := adw.NewBreakpoint(adw.BreakpointConditionParse("max-width: 600sp"))
+	bp.ConnectApply(func() { mw.setCompact(true) })
+	bp.ConnectUnapply(func() { mw.setCompact(false) })
+	mw.Window.AddBreakpoint(bp)
+}
+
+func (mw *HerbariumWindow) setCompact(compact bool) {
+	orientation, spacing := gtk.OrientationHorizontal, uint(12)
+	mw.CardSize = cardSize
+	if compact {
+		orientation, spacing = gtk.OrientationVertical, 6
+		mw.CardSize = compactCardSize
+	}
+
+	mw.SearchBox.SetOrientation(orientation)
+	mw.StatsLabel.SetVisible(!compact)
+	mw.FlowBox.SetRowSpacing(spacing)
+	mw.FlowBox.SetColumnSpacing(spacing)
+	for _, card := range mw.ModCards {
+		card.SetSize(mw.CardSize)
+	}
+}
+
+// setupShortcuts makes the header reachable from the keyboard. The cards
+// handle their own keys, see setupKeys.
+func (mw *HerbariumWindow) setupShortcuts() {
+	mw.addAction("search", func() {
+		mw.SearchBar.SetSearchMode(true)
+		mw.SearchEntry.GrabFocus()
+	})
+	mw.addAction("select-all", func() { mw.setShownEnabled(true) })
+	mw.addAction("deselect-all", func() { mw.setShownEnabled(false) })
+	mw.addAction("launch", func() {
+		if mw.LaunchButton.Sensitive() {
+			mw.launch()
+		}
+	})
+	mw.addAction("menu", mw.MenuButton.Popup)
+
+	accels := map[string][]string{
+		"win.search":       {"<Control>f"},
+		"win.select-all":   {"<Control><Shift>a"},
+		"win.deselect-all": {"<Control><Shift>d"},
+		"win.launch":       {"<Control>Return"},
+		"win.menu":         {"F10"},
+	}
+	for action, keys := range accels {
+		mw.App.App.SetAccelsForAction(action, keys)
+	}
 }
 
 func (mw *HerbariumWindow) setupSearchBar() {
@@ -161,6 +247,7 @@ func (mw *HerbariumWindow) setupSearchBar() {
 	mw.SearchBar.ConnectEntry(mw.SearchEntry)
 
 	mw.SearchToggle.SetIconName("system-search-symbolic")
+	mw.SearchToggle.SetTooltipText(lib.T_("Search (Ctrl+F)"))
 	mw.SearchBar.NotifyProperty("search-mode-enabled", func() {
 		mw.SearchToggle.SetActive(mw.SearchBar.SearchMode())
 	})
@@ -170,16 +257,48 @@ func (mw *HerbariumWindow) setupSearchBar() {
 	mw.Header.PackStart(mw.SearchToggle)
 
 	mw.SelectAllBtn.SetIconName("object-select-symbolic")
-	mw.SelectAllBtn.SetTooltipText(lib.T_("Select all"))
+	mw.SelectAllBtn.SetTooltipText(lib.T_("Select all (Ctrl+Shift+A)"))
 	mw.Header.PackStart(mw.SelectAllBtn)
 
 	mw.DeselectAllBtn.SetIconName("list-remove-symbolic")
-	mw.DeselectAllBtn.SetTooltipText(lib.T_("Clear selection"))
+	mw.DeselectAllBtn.SetTooltipText(lib.T_("Clear selection (Ctrl+Shift+D)"))
 	mw.Header.PackStart(mw.DeselectAllBtn)
 
 	mw.Header.PackEnd(mw.StatsLabel)
 }
 
+func (mw *HerbariumWindow) setupMenu() {
+	menu := gio.NewMenu()
+	menu.Append(lib.T_("Export mod list…"), "win.export")
+	menu.Append(lib.T_("Import mod list…"), "win.import")
+	menu.Append(lib.T_("Import from clipboard"), "win.import-clipboard")
+	menu.Append(lib.T_("Find a crashing mod…"), "win.bisect")
+	menu.Append(lib.T_("Show log"), "win.show-log")
+
+	mw.MenuButton.SetIconName("open-menu-symbolic")
+	mw.MenuButton.SetTooltipText(lib.T_("Main menu (F10)"))
+	mw.MenuButton.SetMenuModel(menu)
+	mw.Header.PackEnd(mw.MenuButton)
+
+	mw.addAction("export", mw.exportModList)
+	mw.addAction("import", mw.importModList)
+	mw.addAction("import-clipboard", mw.importFromClipboard)
+	mw.addAction("bisect", mw.showBisect)
+	mw.addAction("show-log", mw.showLog)
+}
+
+func (mw *HerbariumWindow) addAction(name string, activate func()) {
+	action := gio.NewSimpleAction(name, nil)
+	action.ConnectActivate(func(_ *glib.Variant) {
+		activate()
+	})
+	mw.Window.AddAction(action)
+}
+
+func (mw *HerbariumWindow) toast(text string) {
+	mw.ToastOverlay.AddToast(adw.NewToast(text))
+}
+
 func (mw *HerbariumWindow) setupFlowBox() {
 	mw.FlowBox.SetHomogeneous(true)
 	mw.FlowBox.SetRowSpacing(12)
@@ -211,14 +330,48 @@ func (mw *HerbariumWindow) setupBottomBar() {
 	mw.ToolbarView.AddBottomBar(mw.BottomBox)
 }
 
+// openManager loads the library of the current game. Progress events are
+// handed to the main loop; an error leaves an empty library so the window
+// still opens and shows it.
+func (mw *HerbariumWindow) openManager() {
+	m, err := lib.OpenManager()
+	if err != nil {
+		mw.toast(err.Error())
+		m = lib.NewManager(&lib.Config{}, &lib.ModsDB{})
+	}
+	m.OnEvent = func(e lib.Event) {
+		switch e.Kind {
+		case lib.EventScanning:
+			glib.IdleAdd(func() { mw.showScanProgress(e.Progress) })
+		case lib.EventCopying:
+			glib.IdleAdd(func() { mw.showCopyProgress(e.Copy) })
+		case lib.EventRestored, lib.EventConflict:
+			glib.IdleAdd(func() { mw.toast(e.Message) })
+		case lib.EventCrashed:
+			glib.IdleAdd(func() {
+				if mw.BisectLaunch != nil {
+					mw.BisectLaunch.crashed = true
+					return
+				}
+				mw.showCrash(e.Crash)
+			})
+		}
+	}
+	mw.Manager = m
+}
+
+// loadMods builds the cards from the database as it is; see scan for
+// bringing it up to date first.
 func (mw *HerbariumWindow) loadMods(app *HerbariumApp) {
-	cfg, _ := lib.EnsureConfig()
-	db, _ := lib.EnsureModsDB()
-	lib.ScanAndUpdate(cfg, db)
+	db := mw.Manager.DB
+
+	mw.ModCards = make(map[string]*ModCard)
+	mw.AllModIndices = mw.AllModIndices[:0]
 
 	for i := range db.Mods {
 		mod := &db.Mods[i]
-		card := NewModCard(app, mod, mw.CfgPath, mw.DbPath, func() { mw.updateStats() })
+		card := NewModCard(app, mw.Manager, mod, func() { mw.updateStats() })
+		card.SetSize(mw.CardSize)
 		modID := mod.Folder
 		mw.ModCards[modID] = card
 		mw.AllModIndices = append(mw.AllModIndices, modID)
@@ -239,54 +392,172 @@ func (mw *HerbariumWindow) connectSignals(app *HerbariumApp) {
 	})
 
 	mw.SelectAllBtn.ConnectClicked(func() {
-		anyChanged := false
-		for _, modID := range mw.FilteredModIndices {
-			card := mw.ModCards[modID]
-			if card != nil && !card.ModEntry.Enabled {
-				card.CheckBtn.SetActive(true)
-				anyChanged = true
-			}
-		}
-		if anyChanged {
-			mw.updateStats()
-		}
+		mw.setShownEnabled(true)
 	})
 
 	mw.DeselectAllBtn.ConnectClicked(func() {
-		anyChanged := false
-		for _, modID := range mw.FilteredModIndices {
-			card := mw.ModCards[modID]
-			if card != nil && card.ModEntry.Enabled {
-				card.CheckBtn.SetActive(false)
-				anyChanged = true
-			}
-		}
-		if anyChanged {
-			mw.updateStats()
-		}
+		mw.setShownEnabled(false)
 	})
 
 	mw.LaunchButton.ConnectClicked(func() {
-		mw.LaunchButton.SetSensitive(false)
-		mw.Spinner.SetVisible(true)
-		mw.Spinner.Start()
+		mw.launch()
+	})
 
-		go func() {
-			cfg, _ := lib.EnsureConfig()
-			db, _ := lib.EnsureModsDB()
-			lib.ScanAndUpdate(cfg, db)
+	mw.LaunchButton.SetSensitive(true)
+}
 
-			lib.LaunchWithMods(cfg, db)
+// setShownEnabled enables or disables the mods that pass the filter.
+func (mw *HerbariumWindow) setShownEnabled(enabled bool) {
+	anyChanged := false
+	for _, modID := range mw.FilteredModIndices {
+		card := mw.ModCards[modID]
+		if card != nil && card.ModEntry.Enabled != enabled {
+			card.CheckBtn.SetActive(enabled)
+			anyChanged = true
+		}
+	}
+	if anyChanged {
+		mw.updateStats()
+	}
+}
 
-			glib.IdleAdd(func() {
-				mw.Spinner.Stop()
-				mw.Spinner.SetVisible(false)
-				mw.LaunchButton.SetSensitive(true)
+func (mw *HerbariumWindow) launch() {
+	mw.startLaunch(false)
+}
+
+// launchVanilla starts the game with every mod disabled, leaving the saved
+// selection alone.
+func (mw *HerbariumWindow) launchVanilla() {
+	mw.startLaunch(true)
+}
+
+func (mw *HerbariumWindow) startLaunch(vanilla bool) {
+	// A vanilla launch enables nothing; otherwise the checked cards count.
+	var selection []string
+	if vanilla {
+		selection = []string{}
+	}
+	mw.launchSelection(selection, nil)
+}
+
+// launchSelection launches the game with selection, as for
+// lib.Manager.Launch, and calls done if it is not nil once the game is gone
+// and everything is back in place. A launch declined in the copy dialog
+// ends with context.Canceled.
+func (mw *HerbariumWindow) launchSelection(selection []string, done func(error)) {
+	if mw.Launching {
+		if done != nil {
+			done(&lib.BusyError{})
+		}
+		return
+	}
+	mw.Launching = true
+	mw.LaunchButton.SetSensitive(false)
+	mw.Spinner.SetVisible(true)
+	mw.Spinner.Start()
+
+	go func() {
+		plan := mw.Manager.Plan(selection)
+		if !plan.NeedsCopy() {
+			mw.runLaunch(selection, done)
+			return
+		}
+
+		glib.IdleAdd(func() {
+			mw.confirmLaunch(plan, func(ok bool) {
+				if ok {
+					go mw.runLaunch(selection, done)
+					return
+				}
+				mw.launchFinished()
+				if done != nil {
+					done(context.Canceled)
+				}
 			})
-		}()
+		})
+	}()
+}
+
+func (mw *HerbariumWindow) runLaunch(selection []string, done func(error)) {
+	err := mw.Manager.Launch(context.Background(), selection)
+	glib.IdleAdd(func() {
+		mw.launchFinished()
+		if err != nil {
+			mw.toast(err.Error())
+		}
+		if done != nil {
+			done(err)
+		}
 	})
+}
 
+func (mw *HerbariumWindow) launchFinished() {
+	mw.Launching = false
+	mw.Spinner.Stop()
+	mw.Spinner.SetVisible(false)
 	mw.LaunchButton.SetSensitive(true)
+	mw.LaunchButton.SetLabel(launchLabel())
+}
+
+func launchLabel() string {
+	return fmt.Sprintf(lib.T_("Launch %s"), lib.CurrentGame().DisplayName())
+}
+
+// showCopyProgress shows on the launch button how much of the folders
+// moved to another drive has been copied.
+func (mw *HerbariumWindow) showCopyProgress(p lib.CopyProgress) {
+	if p.Total <= 0 || p.Done >= p.Total {
+		mw.LaunchButton.SetLabel(launchLabel())
+		return
+	}
+	mw.LaunchButton.SetLabel(lib.Format(lib.T_("Copying mods… {percent}%"), lib.Args{"percent": p.Done * 100 / p.Total}))
+}
+
+// confirmLaunch shows the folders that have to be copied to another
+// filesystem before the game starts, which can take a while.
+func (mw *HerbariumWindow) confirmLaunch(plan *lib.MovePlan, done func(bool)) {
+	dialog := adw.NewAlertDialog(
+		lib.T_("Copy disabled mods?"),
+		fmt.Sprintf(lib.T_("The folder for disabled mods is on another drive, so %s will be copied before launch."), lib.FormatSize(plan.CopyBytes)),
+	)
+
+	label := gtk.NewLabel(plan.String())
+	label.SetSelectable(true)
+	label.SetWrap(true)
+	label.SetXAlign(0)
+	label.AddCSSClass("monospace")
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetMinContentHeight(200)
+	scroll.SetChild(label)
+	dialog.SetExtraChild(scroll)
+
+	dialog.AddResponse("cancel", lib.T_("Cancel"))
+	dialog.AddResponse("launch", lib.T_("Launch"))
+	dialog.SetCloseResponse("cancel")
+	if plan.FitsOnDisk() {
+		dialog.SetDefaultResponse("launch")
+		dialog.SetResponseAppearance("launch", adw.ResponseSuggested)
+	} else {
+		dialog.SetResponseAppearance("launch", adw.ResponseDestructive)
+	}
+
+	dialog.ConnectResponse(func(response string) {
+		done(response == "launch")
+	})
+	dialog.Present(mw.Window)
+}
+
+// reload reads the database from disk again and rescans, picking up
+// changes made by the CLI and new folders in the Workshop directory. done
+// runs once the cards are rebuilt.
+func (mw *HerbariumWindow) reload(done func()) {
+	if err := mw.Manager.Reload(); err != nil {
+		mw.toast(err.Error())
+		return
+	}
+	mw.scan(done)
 }
 
 func (mw *HerbariumWindow) scheduleFilterUpdate() {
@@ -333,7 +604,7 @@ func (mw *HerbariumWindow) updateFilter() {
 			id:   modID,
 			card: card,
 			time: card.ModEntry.DiscoveredAt,
-			name: strings.ToLower(card.ModEntry.Name),
+			name: strings.ToLower(card.ModEntry.DisplayName()),
 		})
 	}
 
@@ -370,7 +641,7 @@ func (mw *HerbariumWindow) updateFilter() {
 
 		matchesSearch := true
 		if searchText != "" {
-			matchesSearch = strings.Contains(strings.ToLower(mod.Name), strings.ToLower(searchText))
+			matchesSearch = strings.Contains(strings.ToLower(mod.DisplayName()), strings.ToLower(searchText))
 		}
 
 		matchesState := true
@@ -427,9 +698,10 @@ func (mw *HerbariumWindow) updateStats() {
 		}
 	}
 
-	statsText := fmt.Sprintf("%s: %d | %s: %d | %s: %d",
-		lib.T_("Total"), total,
-		lib.T_("Enabled"), enabled,
-		lib.T_("Disabled"), disabled)
+	statsText := strings.Join([]string{
+		lib.Format(lib.NT_("stats", "{count} mod", "{count} mods", total), lib.Args{"count": total}),
+		lib.Format(lib.NT_("stats", "{count} enabled", "{count} enabled", enabled), lib.Args{"count": enabled}),
+		lib.Format(lib.NT_("stats", "{count} disabled", "{count} disabled", disabled), lib.Args{"count": disabled}),
+	}, " | ")
 	mw.StatsLabel.SetText(statsText)
 }
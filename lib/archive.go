@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InstallModArchive unpacks a .zip mod into the Workshop directory and
+// returns the folder it was put in. An archive holding a single top-level
+// directory is unpacked without that extra level.
+func InstallModArchive(cfg *Config, path string) (string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	folder := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	folder = sanitizeName(strings.TrimPrefix(folder, "."))
+	if folder == "" {
+		return "", errors.New(T_("bad archive name"))
+	}
+
+	dst := filepath.Join(cfg.Root, folder)
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf(T_("mod folder already exists: %s"), folder)
+	}
+
+	prefix := commonZipPrefix(r.File)
+
+	tmp := dst + ".partial"
+	os.RemoveAll(tmp)
+	for _, f := range r.File {
+		name := strings.TrimPrefix(f.Name, prefix)
+		if name == "" {
+			continue
+		}
+		if err := extractZipFile(f, tmp, name); err != nil {
+			os.RemoveAll(tmp)
+			return "", err
+		}
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return folder, nil
+}
+
+// commonZipPrefix returns "dir/" if every entry lives under one directory.
+func commonZipPrefix(files []*zip.File) string {
+	prefix := ""
+	for _, f := range files {
+		top, _, found := strings.Cut(f.Name, "/")
+		if !found {
+			return ""
+		}
+		if prefix == "" {
+			prefix = top + "/"
+		} else if prefix != top+"/" {
+			return ""
+		}
+	}
+	return prefix
+}
+
+func extractZipFile(f *zip.File, root, name string) error {
+	target := filepath.Join(root, filepath.FromSlash(name))
+	if !strings.HasPrefix(target, filepath.Clean(root)+string(filepath.Separator)) {
+		return fmt.Errorf(T_("archive entry escapes the mod folder: %s"), f.Name)
+	}
+
+	if f.FileInfo().IsDir() {
+		return os.MkdirAll(target, 0755)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
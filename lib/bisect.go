@@ -0,0 +1,260 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stages of a bisect frame. A frame first tests each half of its
+// candidates alone; if neither crashes, the culprits are spread over both
+// halves, and each half is narrowed down with the other one enabled.
+const (
+	bisectFirstHalf = iota
+	bisectSecondHalf
+	bisectFirstPart
+	bisectSecondPart
+)
+
+// Results of a finished bisect.
+const (
+	// BisectFound means the game crashed with the culprits alone.
+	BisectFound = "found"
+	// BisectNoCrash means the game did not crash with all the mods, so
+	// there was nothing to search.
+	BisectNoCrash = "no-crash"
+	// BisectUnconfirmed means the culprits alone did not crash the game:
+	// the crash depends on something else or does not always happen.
+	BisectUnconfirmed = "unconfirmed"
+)
+
+// bisectFrame narrows Candidates down to the fewest mods that still crash
+// the game together with Context.
+type bisectFrame struct {
+	Context    []string `yaml:"context,omitempty"`
+	Candidates []string `yaml:"candidates"`
+	Stage      int      `yaml:"stage"`
+	Found      []string `yaml:"found,omitempty"`
+}
+
+func (f *bisectFrame) halves() ([]string, []string) {
+	n := len(f.Candidates) / 2
+	return f.Candidates[:n], f.Candidates[n:]
+}
+
+// Bisect searches the mods enabled when it started for the smallest set
+// that crashes the game, over several launches, like git bisect. Each step
+// launches a selection of the mods, and the player tells whether the game
+// crashed. The first step launches all of them, to make sure there is a
+// crash to look for, and the last one the culprits alone, to confirm them.
+// The saved Enabled flags are never changed.
+type Bisect struct {
+	StartedAt time.Time `yaml:"started_at"`
+	Mods      []string  `yaml:"mods"`
+	Steps     int       `yaml:"steps"`
+	// Confirmed is set once the game crashed with all of Mods.
+	Confirmed bool          `yaml:"confirmed,omitempty"`
+	Stack     []bisectFrame `yaml:"stack,omitempty"`
+	Culprits  []string      `yaml:"culprits,omitempty"`
+	Result    string        `yaml:"result,omitempty"`
+}
+
+// newBisect starts a search over mods, which are expected to crash the
+// game together while the game without mods does not.
+func newBisect(mods []string) *Bisect {
+	return &Bisect{StartedAt: time.Now(), Mods: mods, Stack: []bisectFrame{{Candidates: mods}}}
+}
+
+// Done reports whether the bisect has a Result.
+func (b *Bisect) Done() bool {
+	return b.Result != ""
+}
+
+// Left returns how many mods are still suspected in the current step.
+func (b *Bisect) Left() int {
+	switch {
+	case !b.Confirmed:
+		return len(b.Mods)
+	case len(b.Stack) > 0:
+		return len(b.Stack[len(b.Stack)-1].Candidates)
+	}
+	return len(b.Culprits)
+}
+
+// Selection returns the folders to launch with for the current step.
+func (b *Bisect) Selection() []string {
+	switch {
+	case !b.Confirmed && !b.Done():
+		return slices.Clone(b.Mods)
+	case len(b.Stack) == 0:
+		return slices.Clone(b.Culprits)
+	}
+	top := &b.Stack[len(b.Stack)-1]
+	first, second := top.halves()
+	if top.Stage == bisectFirstHalf {
+		return union(top.Context, first)
+	}
+	return union(top.Context, second)
+}
+
+// Mark records whether the game crashed with the current selection and
+// moves on to the next step.
+func (b *Bisect) Mark(crashed bool) {
+	if b.Done() {
+		return
+	}
+	b.Steps++
+
+	switch {
+	case !b.Confirmed && !crashed:
+		b.Stack, b.Result = nil, BisectNoCrash
+		return
+	case !b.Confirmed:
+		b.Confirmed = true
+		b.settle()
+		// A single mod was just launched alone.
+		if len(b.Stack) == 0 {
+			b.Result = BisectFound
+		}
+		return
+	case len(b.Stack) == 0:
+		b.Result = BisectUnconfirmed
+		if crashed {
+			b.Result = BisectFound
+		}
+		return
+	}
+
+	top := &b.Stack[len(b.Stack)-1]
+	first, second := top.halves()
+	switch {
+	case top.Stage == bisectFirstHalf && crashed:
+		*top = bisectFrame{Context: top.Context, Candidates: first}
+	case top.Stage == bisectFirstHalf:
+		top.Stage = bisectSecondHalf
+	case crashed:
+		*top = bisectFrame{Context: top.Context, Candidates: second}
+	default:
+		top.Stage = bisectFirstPart
+		b.Stack = append(b.Stack, bisectFrame{Context: union(top.Context, second), Candidates: first})
+	}
+	b.settle()
+}
+
+// Skip leaves the current step undecided, for a launch that showed
+// nothing, such as a game closed before it could crash. The suspects are
+// split differently, so the next step does not launch the same selection
+// unless there is no other.
+func (b *Bisect) Skip() {
+	if b.Done() {
+		return
+	}
+	if !b.Confirmed || len(b.Stack) == 0 {
+		return
+	}
+	top := &b.Stack[len(b.Stack)-1]
+	// A new slice, since the candidates share their array with the frame
+	// below.
+	top.Candidates = append(slices.Clone(top.Candidates[1:]), top.Candidates[0])
+	top.Stage = bisectFirstHalf
+}
+
+// settle finishes the frames that are down to one candidate, handing the
+// result to the frame below, until one needs a launch or none are left.
+func (b *Bisect) settle() {
+	for len(b.Stack) > 0 {
+		top := b.Stack[len(b.Stack)-1]
+		if len(top.Candidates) > 1 {
+			return
+		}
+		result := top.Candidates
+		b.Stack = b.Stack[:len(b.Stack)-1]
+
+		for {
+			if len(b.Stack) == 0 {
+				b.Culprits = result
+				return
+			}
+			parent := &b.Stack[len(b.Stack)-1]
+			if parent.Stage == bisectFirstPart {
+				// The first half is narrowed down; now the second,
+				// with what was found enabled.
+				parent.Found = result
+				parent.Stage = bisectSecondPart
+				_, second := parent.halves()
+				b.Stack = append(b.Stack, bisectFrame{Context: union(parent.Context, result), Candidates: second})
+				break
+			}
+			result = union(parent.Found, result)
+			b.Stack = b.Stack[:len(b.Stack)-1]
+		}
+	}
+}
+
+func union(a, b []string) []string {
+	out := slices.Clone(a)
+	for _, s := range b {
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func bisectPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bisect.yaml"), nil
+}
+
+// LoadBisect returns the bisect in progress, or NoBisectError.
+func LoadBisect() (*Bisect, error) {
+	path, err := bisectPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, &NoBisectError{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bisect Bisect
+	if err := yaml.Unmarshal(b, &bisect); err != nil {
+		return nil, err
+	}
+	return &bisect, nil
+}
+
+func saveBisect(b *Bisect) error {
+	path, err := bisectPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ResetBisect ends the bisect in progress, if any.
+func ResetBisect() error {
+	path, err := bisectPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"slices"
+	"testing"
+)
+
+// runBisect answers each step of a bisect over mods with crashes until it
+// is done, and returns it.
+func runBisect(t *testing.T, mods []string, crashes func(sel []string) bool) *Bisect {
+	t.Helper()
+	b := newBisect(mods)
+	for i := 0; !b.Done(); i++ {
+		if i > 4*len(mods)+2 {
+			t.Fatalf("bisect did not finish: %+v", b)
+		}
+		b.Mark(crashes(b.Selection()))
+	}
+	return b
+}
+
+func TestBisect(t *testing.T) {
+	mods := []string{"a", "b", "c", "d", "e"}
+	b := runBisect(t, mods, func(sel []string) bool {
+		return slices.Contains(sel, "b") && slices.Contains(sel, "e")
+	})
+	if b.Result != BisectFound || !slices.Equal(b.Culprits, []string{"b", "e"}) {
+		t.Errorf("result = %s, culprits = %v", b.Result, b.Culprits)
+	}
+}
+
+func TestBisectSingleMod(t *testing.T) {
+	b := runBisect(t, []string{"a"}, func([]string) bool { return true })
+	if b.Result != BisectFound || b.Steps != 1 {
+		t.Errorf("result = %s after %d steps", b.Result, b.Steps)
+	}
+}
+
+func TestBisectWithoutCrash(t *testing.T) {
+	b := runBisect(t, []string{"a", "b"}, func([]string) bool { return false })
+	if b.Result != BisectNoCrash || b.Steps != 1 {
+		t.Errorf("result = %s after %d steps", b.Result, b.Steps)
+	}
+}
+
+func TestBisectUnconfirmed(t *testing.T) {
+	// A crash that only happened on the first launch leaves the search
+	// with suspects that never crashed on their own.
+	first := true
+	b := runBisect(t, []string{"a", "b", "c", "d"}, func([]string) bool {
+		crashed := first
+		first = false
+		return crashed
+	})
+	if b.Result != BisectUnconfirmed {
+		t.Errorf("result = %s, culprits = %v", b.Result, b.Culprits)
+	}
+}
+
+func TestBisectSkip(t *testing.T) {
+	b := newBisect([]string{"a", "b", "c", "d"})
+	b.Mark(true)
+	before := b.Selection()
+	b.Skip()
+	if slices.Equal(b.Selection(), before) {
+		t.Errorf("skip launches %v again", before)
+	}
+	for !b.Done() {
+		b.Mark(slices.Contains(b.Selection(), "c"))
+	}
+	if b.Result != BisectFound || !slices.Equal(b.Culprits, []string{"c"}) {
+		t.Errorf("result = %s, culprits = %v", b.Result, b.Culprits)
+	}
+}
@@ -0,0 +1,102 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// fileTypeCollection marks a nested collection among collection children.
+const fileTypeCollection = 2
+
+// CollectionDiff compares a Workshop collection with the installed mods.
+type CollectionDiff struct {
+	Installed []SharedMod
+	Missing   []SharedMod
+	Extra     []ModEntry
+}
+
+// ParseWorkshopID accepts a bare ID or a Workshop page URL with ?id=.
+func ParseWorkshopID(s string) string {
+	if u, err := url.Parse(s); err == nil && u.Query().Get("id") != "" {
+		return u.Query().Get("id")
+	}
+	return strings.TrimSpace(s)
+}
+
+// FetchCollection asks Steam for the items of a Workshop collection and
+// returns them, with titles, as a mod list.
+func FetchCollection(ctx context.Context, cfg *Config, id string) (*ModList, error) {
+	form := url.Values{}
+	form.Set("collectioncount", "1")
+	form.Set("publishedfileids[0]", id)
+
+	var sr steamRespGetCollectionDetails
+	if err := callSteamRemoteStorage(ctx, cfg, "GetCollectionDetails", form, &sr); err != nil {
+		return nil, err
+	}
+
+	details := sr.Response.CollectionDetails
+	if len(details) == 0 || details[0].Result != 1 {
+		return nil, errors.New(T_("collection not found"))
+	}
+
+	list := &ModList{Version: modListVersion, AppID: CurrentGame().AppID}
+	var ids []string
+	for _, c := range details[0].Children {
+		if c.FileType == fileTypeCollection {
+			continue
+		}
+		ids = append(ids, c.PublishedFileID)
+		list.Mods = append(list.Mods, SharedMod{ID: c.PublishedFileID})
+	}
+
+	if len(ids) == 0 {
+		return list, nil
+	}
+
+	// Titles are nice to have; the collection is still usable without them.
+	files, err := fetchPublishedFileDetails(ctx, cfg, append([]string{id}, ids...))
+	if err != nil {
+		return list, nil
+	}
+	titles := map[string]string{}
+	for _, f := range files {
+		titles[f.PublishedFileID] = f.Title
+	}
+	list.Name = titles[id]
+	for i := range list.Mods {
+		list.Mods[i].Name = titles[list.Mods[i].ID]
+	}
+	return list, nil
+}
+
+func DiffCollection(db *ModsDB, list *ModList) *CollectionDiff {
+	installed := map[string]ModEntry{}
+	for _, m := range db.Mods {
+		installed[m.Folder] = m
+	}
+
+	diff := &CollectionDiff{}
+	inList := map[string]bool{}
+	for _, item := range list.Mods {
+		inList[item.ID] = true
+		if m, ok := installed[item.ID]; ok {
+			if item.Name == "" {
+				item.Name = m.DisplayName()
+			}
+			item.CodeName = m.CodeName
+			diff.Installed = append(diff.Installed, item)
+		} else {
+			diff.Missing = append(diff.Missing, item)
+		}
+	}
+
+	for _, m := range db.Mods {
+		if !inList[m.Folder] {
+			diff.Extra = append(diff.Extra, m)
+		}
+	}
+	return diff
+}
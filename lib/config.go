@@ -1,7 +1,6 @@
 package lib
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -11,12 +10,7 @@ import (
 
 const appConfigDir = "ru.ximper.Herbarium"
 
-var (
-	modsLineRe = regexp.MustCompile(
-		`(?m)^\s*\$?\s*mods\s*\[\s*["']([^"'\]]+)["']\s*\]\s*=\s*u?["']([\s\S]*?)["']`,
-	)
-	braceRe = regexp.MustCompile(`\{[^}]*\}`)
-)
+var braceRe = regexp.MustCompile(`\{[^}]*\}`)
 
 func configDir() (string, error) {
 	base, err := os.UserConfigDir()
@@ -30,16 +24,47 @@ func configDir() (string, error) {
 	return dir, nil
 }
 
-func configPath() (string, error) {
+// dataDir is the XDG data directory of the current game, used for things
+// that are not configuration, such as save snapshots.
+func dataDir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(base, appConfigDir, CurrentGame().namespace())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// gameConfigDir holds the config and database of the current game.
+func gameConfigDir() (string, error) {
 	dir, err := configDir()
 	if err != nil {
 		return "", err
 	}
+	dir = filepath.Join(dir, CurrentGame().namespace())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func configPath() (string, error) {
+	dir, err := gameConfigDir()
+	if err != nil {
+		return "", err
+	}
 	return filepath.Join(dir, "config.yaml"), nil
 }
 
 func modsDBPath() (string, error) {
-	dir, err := configDir()
+	dir, err := gameConfigDir()
 	if err != nil {
 		return "", err
 	}
@@ -53,10 +78,19 @@ func EnsureConfig() (*Config, error) {
 
 	home, _ := os.UserHomeDir()
 	c := &Config{
+		Launcher:    LauncherSteam,
 		GameExe:     "/usr/bin/steam",
-		Args:        []string{"-applaunch", "331470"},
-		Root:        filepath.Join(home, ".steam/steam/steamapps/workshop/content/331470"),
-		DisabledDir: filepath.Join(home, ".elmod_disabled"),
+		Root:        filepath.Join(home, ".steam/steam/steamapps/workshop/content", CurrentGame().AppID),
+		DisabledDir: CurrentGame().defaultDisabledDir(),
+	}
+
+	if inst, err := DetectSteamInstall(); err == nil {
+		c.Root = inst.Workshop
+		c.GameDir = inst.GameDir
+		if inst.Flatpak {
+			c.Launcher = LauncherFlatpakSteam
+			c.GameExe = ""
+		}
 	}
 	return c, SaveConfig(c)
 }
@@ -70,50 +104,15 @@ func EnsureModsDB() (*ModsDB, error) {
 	return db, SaveModsDB(db)
 }
 
-func ToggleEnabled(enable bool, id string) error {
-	if id == "" {
-		return fmt.Errorf("provide folder id, codename, or ALL")
-	}
-
-	cfg, err := EnsureConfig()
-	if err != nil {
-		return err
-	}
-
-	db, err := EnsureModsDB()
+func setEnabled(db *ModsDB, id string, enabled bool) error {
+	m, err := findMod(db, id)
 	if err != nil {
 		return err
 	}
-
-	ScanAndUpdate(cfg, db)
-
-	if id == "ALL" {
-		setAllEnabled(db, enable)
-	} else {
-		if err := setEnabled(db, id, enable); err != nil {
-			return err
-		}
-	}
-
-	if err := SaveModsDB(db); err != nil {
-		return err
-	}
-
-	action := map[bool]string{true: "Enabled", false: "Disabled"}[enable]
-	fmt.Printf("%s %s\n", action, id)
+	m.Enabled = enabled
 	return nil
 }
 
-func setEnabled(db *ModsDB, id string, enabled bool) error {
-	for i, m := range db.Mods {
-		if m.Folder == id || m.CodeName == id {
-			db.Mods[i].Enabled = enabled
-			return nil
-		}
-	}
-	return fmt.Errorf("mod not found: %s", id)
-}
-
 func setAllEnabled(db *ModsDB, enabled bool) {
 	for i := range db.Mods {
 		db.Mods[i].Enabled = enabled
@@ -131,7 +130,10 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 	var c Config
-	return &c, yaml.Unmarshal(b, &c)
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return &c, err
+	}
+	return &c, nil
 }
 
 func SaveConfig(c *Config) error {
@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Which copy a restore conflict kept.
+const (
+	ConflictKeptWorkshop = "workshop"
+	ConflictKeptDisabled = "disabled"
+)
+
+// quarantineDir is where the losing copies of restore conflicts go, inside
+// the disabled mods directory so that moving them there is a rename.
+const quarantineDir = ".quarantine"
+
+// RestoreConflict records a mod folder that was found in the Workshop
+// folder again when it was to be restored, usually because Steam
+// downloaded it while it was disabled, or because a move failed while
+// deleting it and left Partial files behind. The newer or complete copy is
+// kept; the other one is moved to Quarantined, or deleted when both were
+// the same.
+type RestoreConflict struct {
+	Folder      string    `yaml:"folder"`
+	Kept        string    `yaml:"kept"`
+	Identical   bool      `yaml:"identical,omitempty"`
+	Partial     bool      `yaml:"partial,omitempty"`
+	Quarantined string    `yaml:"quarantined,omitempty"`
+	At          time.Time `yaml:"at"`
+}
+
+func (c *RestoreConflict) String() string {
+	if c.Partial {
+		return Format(T_("{folder} was left half-deleted by an earlier move; restored the complete copy, the rest is in {path}"),
+			Args{"folder": c.Folder, "path": c.Quarantined})
+	}
+	if c.Identical {
+		return Format(T_("{folder} was downloaded again by Steam; the copies were the same, the disabled one was removed"),
+			Args{"folder": c.Folder})
+	}
+	msg := T_("{folder} was downloaded again by Steam; kept the newer Workshop copy, the other one is in {path}")
+	if c.Kept == ConflictKeptDisabled {
+		msg = T_("{folder} was downloaded again by Steam; kept the newer disabled copy, the other one is in {path}")
+	}
+	return Format(msg, Args{"folder": c.Folder, "path": c.Quarantined})
+}
+
+func conflictsPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "conflicts.yaml"), nil
+}
+
+// LoadRestoreConflicts returns the restore conflicts recorded so far,
+// oldest first.
+func LoadRestoreConflicts() ([]RestoreConflict, error) {
+	path, err := conflictsPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var conflicts []RestoreConflict
+	if err := yaml.Unmarshal(b, &conflicts); err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+func recordConflict(c *RestoreConflict) error {
+	conflicts, err := LoadRestoreConflicts()
+	if err != nil {
+		return err
+	}
+	path, err := conflictsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(append(conflicts, *c))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// resolveConflict restores the disabled copy src over the Workshop copy
+// dst, both of the same mod. Same contents need no choice. A Workshop copy
+// holding nothing but unchanged files of the disabled one is what a move
+// that failed while deleting it left behind, and the disabled copy wins.
+// Otherwise the copy with the most recently modified file wins. The
+// losing copy goes to quarantine.
+func (c *copier) resolveConflict(src, dst string) (*RestoreConflict, error) {
+	conflict := &RestoreConflict{Folder: filepath.Base(dst), Kept: ConflictKeptWorkshop, At: time.Now()}
+
+	manifest, err := BuildManifest(c.ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+	report, err := manifest.Verify(c.ctx, src)
+	if err == nil && report.OK() {
+		conflict.Identical = true
+		if err := os.RemoveAll(src); err != nil {
+			return nil, err
+		}
+		return conflict, recordConflict(conflict)
+	}
+
+	dir := filepath.Join(filepath.Dir(src), quarantineDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	conflict.Quarantined = filepath.Join(dir, conflict.Folder+"-"+conflict.At.Format("2006-01-02_15-04-05"))
+
+	conflict.Partial = err == nil && len(report.Missing) == 0 && len(report.Modified) == 0
+	if !conflict.Partial && !latestModTime(src).After(latestModTime(dst)) {
+		if err := c.moveDir(src, conflict.Quarantined); err != nil {
+			return nil, err
+		}
+		return conflict, recordConflict(conflict)
+	}
+
+	conflict.Kept = ConflictKeptDisabled
+	if err := c.moveDir(dst, conflict.Quarantined); err != nil {
+		return nil, err
+	}
+	if err := c.moveDir(src, dst); err != nil {
+		return nil, err
+	}
+	return conflict, recordConflict(conflict)
+}
+
+// latestModTime returns the modification time of the newest file under
+// dir.
+func latestModTime(dir string) time.Time {
+	var latest time.Time
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil && info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+		}
+		return nil
+	})
+	return latest
+}
@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string, mtime time.Time) {
+	t.Helper()
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRestoreReplacesPartialSource(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	workshop, disabled := t.TempDir(), t.TempDir()
+	src, dst := filepath.Join(workshop, "1"), filepath.Join(disabled, "1")
+
+	// The copy made it to dst, then deleting the source stopped half-way
+	// through, leaving files that are newer than the rest.
+	old := time.Now().Add(-time.Hour)
+	writeFiles(t, dst, map[string]string{"a.rpy": "a", "b.rpy": "b"}, old)
+	writeFiles(t, src, map[string]string{"b.rpy": "b"}, time.Now())
+
+	conflicts, err := restoreMoved([][2]string{{src, dst}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || !conflicts[0].Partial || conflicts[0].Kept != ConflictKeptDisabled {
+		t.Fatalf("conflicts = %+v", conflicts)
+	}
+	if _, err := os.Stat(filepath.Join(src, "a.rpy")); err != nil {
+		t.Error("the complete copy was not restored")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Error("the disabled copy is still there")
+	}
+}
+
+func TestRestoreKeepsNewerDownload(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	workshop, disabled := t.TempDir(), t.TempDir()
+	src, dst := filepath.Join(workshop, "1"), filepath.Join(disabled, "1")
+
+	writeFiles(t, dst, map[string]string{"a.rpy": "a"}, time.Now().Add(-time.Hour))
+	writeFiles(t, src, map[string]string{"a.rpy": "a2"}, time.Now())
+
+	conflicts, err := restoreMoved([][2]string{{src, dst}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Partial || conflicts[0].Kept != ConflictKeptWorkshop {
+		t.Fatalf("conflicts = %+v", conflicts)
+	}
+	if b, _ := os.ReadFile(filepath.Join(src, "a.rpy")); string(b) != "a2" {
+		t.Errorf("a.rpy = %q", b)
+	}
+}
@@ -0,0 +1,263 @@
+package lib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// copyProgressInterval limits how often a copy reports progress.
+const copyProgressInterval = 100 * time.Millisecond
+
+// CopyProgress reports a copy across filesystems. Done and Total count the
+// bytes of every folder in the batch.
+type CopyProgress struct {
+	Path  string
+	Done  int64
+	Total int64
+}
+
+// copier moves and copies folder trees. Files are streamed, and modes,
+// symlinks and modification times are kept. A copy that fails is removed,
+// so the source is the only complete version until the copy is verified.
+type copier struct {
+	ctx      context.Context
+	progress func(CopyProgress)
+	total    int64
+	done     int64
+	reported time.Time
+	buf      []byte
+}
+
+// newCopier returns a copier for a batch of total bytes. progress may be
+// nil.
+func newCopier(ctx context.Context, total int64, progress func(CopyProgress)) *copier {
+	return &copier{ctx: ctx, total: total, progress: progress, buf: make([]byte, 1<<20)}
+}
+
+// moveDir renames src to dst, falling back to copy and delete when they
+// are on different filesystems. src is only deleted once the copy has been
+// checked against it.
+func moveDir(src, dst string) error {
+	return newCopier(context.Background(), 0, nil).moveDir(src, dst)
+}
+
+func (c *copier) moveDir(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := checkFreeSpace(filepath.Dir(dst), dirSize(src)); err != nil {
+		return err
+	}
+
+	// Copy next to the destination first, so that dst never holds a
+	// partial copy if we are interrupted.
+	tmp := dst + ".partial"
+	os.RemoveAll(tmp)
+	manifest, err := c.copyTree(src, tmp)
+	if err != nil {
+		return err
+	}
+	if report, err := manifest.Verify(c.ctx, tmp); err != nil || !report.OK() {
+		os.RemoveAll(tmp)
+		if err != nil {
+			return err
+		}
+		return &VerifyError{Dir: src, Report: report}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies the folder src to dst and returns the manifest of what
+// it read. On failure everything it created is removed again.
+func (c *copier) copyTree(src, dst string) (*Manifest, error) {
+	manifest := &Manifest{Folder: filepath.Base(src), CreatedAt: time.Now().UTC()}
+
+	created := dst
+	for dir := filepath.Dir(dst); ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		created = dir
+	}
+
+	// Directory modes and times are set last: a read-only directory could
+	// not be filled, and adding files changes the time.
+	type dirAttrs struct {
+		path  string
+		mode  fs.FileMode
+		mtime time.Time
+	}
+	var dirs []dirAttrs
+
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirAttrs{target, info.Mode().Perm(), info.ModTime()})
+
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, ManifestFile{Path: filepath.ToSlash(rel), Link: link})
+
+		case info.Mode().IsRegular():
+			sum, err := c.copyFile(p, target, info)
+			if err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, ManifestFile{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: sum})
+
+		default:
+			slog.Warn("skipping special file", "path", p)
+		}
+		return nil
+	})
+
+	if err == nil {
+		for i := len(dirs) - 1; i >= 0 && err == nil; i-- {
+			if err = os.Chmod(dirs[i].path, dirs[i].mode); err == nil {
+				err = os.Chtimes(dirs[i].path, dirs[i].mtime, dirs[i].mtime)
+			}
+		}
+	}
+	if err != nil {
+		os.RemoveAll(created)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// copyFile streams one file, keeping its mode and times, and returns the
+// hash of the bytes read.
+func (c *copier) copyFile(src, dst string, info fs.FileInfo) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	w := &progressWriter{c: c, path: src, w: io.MultiWriter(out, h)}
+	if _, err := io.CopyBuffer(w, in, c.buf); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	c.report(src, true)
+
+	// The umask may have dropped bits from the mode given to OpenFile.
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	if err := os.Chtimes(dst, time.Now(), info.ModTime()); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *copier) report(path string, force bool) {
+	if c.progress == nil {
+		return
+	}
+	if !force && time.Since(c.reported) < copyProgressInterval {
+		return
+	}
+	c.reported = time.Now()
+	c.progress(CopyProgress{Path: path, Done: c.done, Total: max(c.total, c.done)})
+}
+
+// finish reports the batch as done, in case files shrank since its size
+// was taken.
+func (c *copier) finish() {
+	if c.total > 0 {
+		c.total = c.done
+		c.report("", true)
+	}
+}
+
+// progressWriter counts the bytes of a copy and stops it when the copier's
+// context is done.
+type progressWriter struct {
+	c    *copier
+	path string
+	w    io.Writer
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	if err := pw.c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := pw.w.Write(p)
+	pw.c.done += int64(n)
+	pw.c.report(pw.path, false)
+	return n, err
+}
+
+// copyBytes returns how much of the moves has to be copied because source
+// and destination are on different filesystems.
+func copyBytes(moves [][2]string) int64 {
+	var total int64
+	for _, m := range moves {
+		if !sameDevice(m[0], filepath.Dir(m[1])) {
+			total += dirSize(m[0])
+		}
+	}
+	return total
+}
+
+// checkFreeSpace fails when dir has less than need bytes free. Unknown free
+// space is taken as enough.
+func checkFreeSpace(dir string, need int64) error {
+	free := freeSpace(dir)
+	if free >= 0 && need > free {
+		return &NoSpaceError{Dir: dir, Need: need, Free: free}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxCrashReports is how many crash reports are kept.
+const maxCrashReports = 20
+
+// crashFiles are written by Ren'Py into the game directory, or into its
+// directory under ~/.renpy when that is not writable: traceback.txt for
+// errors while the game runs, errors.txt for scripts that do not load.
+var crashFiles = []string{"traceback.txt", "errors.txt"}
+
+// tracebackFrame matches the file of a frame in both files, as in
+// `File "game/script.rpy", line 12, in script`.
+var tracebackFrame = regexp.MustCompile(`File "([^"]+)", line (\d+)`)
+
+// CrashSuspect is a mod that appears in a traceback.
+type CrashSuspect struct {
+	Folder string `yaml:"folder"`
+	Name   string `yaml:"name"`
+	File   string `yaml:"file"`
+	Line   string `yaml:"line"`
+}
+
+// CrashReport describes a session that ended with an error exit or a new
+// Ren'Py traceback. Suspects are ordered from the innermost frame out, so
+// the first one is the most likely cause.
+type CrashReport struct {
+	At        time.Time      `yaml:"at"`
+	ExitError string         `yaml:"exit_error,omitempty"`
+	File      string         `yaml:"file,omitempty"`
+	Error     string         `yaml:"error,omitempty"`
+	Traceback string         `yaml:"traceback,omitempty"`
+	Suspects  []CrashSuspect `yaml:"suspects,omitempty"`
+}
+
+// Summary says that the game crashed and which mod probably caused it.
+func (r *CrashReport) Summary() string {
+	if r.File == "" {
+		return Format(T_("The game exited with an error and left no traceback: {err}"), Args{"err": r.ExitError})
+	}
+	if len(r.Suspects) == 0 {
+		return T_("The game crashed; no mod appears in the traceback.")
+	}
+	s := r.Suspects[0]
+	return Format(T_("The game crashed, probably caused by mod {name} ({folder})."), Args{"name": s.Name, "folder": s.Folder})
+}
+
+// detectCrash looks for signs of a crash after a session that started at
+// started: a traceback written since then, or the game's exit error. It
+// returns nil when the game exited cleanly.
+func detectCrash(cfg *Config, db *ModsDB, started time.Time, gameErr error) *CrashReport {
+	report := &CrashReport{At: time.Now()}
+	if gameErr != nil {
+		report.ExitError = gameErr.Error()
+	}
+
+	dirs := append([]string{cfg.GameDir}, SaveDirs(cfg)...)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		for _, name := range crashFiles {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Before(started) {
+				continue
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			report.File = path
+			report.Traceback = string(b)
+			break
+		}
+		if report.File != "" {
+			break
+		}
+	}
+
+	if report.File == "" && gameErr == nil {
+		return nil
+	}
+	report.Error = tracebackError(report.Traceback)
+	report.Suspects = crashSuspects(cfg, db, report.Traceback)
+	return report
+}
+
+// tracebackError returns the exception line of a traceback: the last line
+// of its first section, which is the short one.
+func tracebackError(traceback string) string {
+	first, _, _ := strings.Cut(traceback, "-- Full Traceback")
+	lines := strings.Split(strings.TrimSpace(first), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// crashSuspects maps the files of a traceback to mods, by a path inside the
+// Workshop root or a path element that is a mod folder.
+func crashSuspects(cfg *Config, db *ModsDB, traceback string) []CrashSuspect {
+	folders := map[string]*ModEntry{}
+	for i := range db.Mods {
+		folders[db.Mods[i].Folder] = &db.Mods[i]
+	}
+
+	matches := tracebackFrame.FindAllStringSubmatch(traceback, -1)
+	var suspects []CrashSuspect
+	seen := map[string]bool{}
+	for i := len(matches) - 1; i >= 0; i-- {
+		file, line := matches[i][1], matches[i][2]
+		path := filepath.ToSlash(file)
+		if rel, err := filepath.Rel(cfg.Root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			path = filepath.ToSlash(rel)
+		}
+
+		for _, elem := range strings.Split(path, "/") {
+			mod, ok := folders[elem]
+			if !ok {
+				continue
+			}
+			if !seen[mod.Folder] {
+				seen[mod.Folder] = true
+				suspects = append(suspects, CrashSuspect{Folder: mod.Folder, Name: mod.DisplayName(), File: file, Line: line})
+			}
+			break
+		}
+	}
+	return suspects
+}
+
+func crashesPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "crashes.yaml"), nil
+}
+
+// LoadCrashReports returns the kept crash reports, oldest first.
+func LoadCrashReports() ([]CrashReport, error) {
+	path, err := crashesPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var reports []CrashReport
+	if err := yaml.Unmarshal(b, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// saveCrashReport adds a report to the history, dropping the oldest ones
+// beyond maxCrashReports.
+func saveCrashReport(r *CrashReport) error {
+	reports, err := LoadCrashReports()
+	if err != nil {
+		return err
+	}
+	reports = append(reports, *r)
+	if len(reports) > maxCrashReports {
+		reports = reports[len(reports)-maxCrashReports:]
+	}
+
+	path, err := crashesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(reports)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
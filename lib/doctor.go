@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Diagnostic is a single line of the `doctor` report.
+type Diagnostic struct {
+	OK      bool
+	Message string
+}
+
+func Diagnose(cfg *Config) []Diagnostic {
+	var out []Diagnostic
+	add := func(ok bool, format string, args ...any) {
+		out = append(out, Diagnostic{OK: ok, Message: fmt.Sprintf(format, args...)})
+	}
+
+	inst, err := DetectSteamInstall()
+	if len(inst.Roots) == 0 {
+		add(false, "%s", T_("No Steam installation found"))
+	}
+	for _, root := range inst.Roots {
+		add(true, T_("Steam root: %s"), root)
+		for _, lib := range SteamLibraries(root) {
+			add(true, T_("  library: %s"), lib)
+		}
+	}
+
+	if err != nil {
+		add(false, T_("%s is not installed in any Steam library"), CurrentGame().DisplayName())
+	} else {
+		add(true, T_("Game library: %s"), inst.Library)
+		if inst.GameDir != "" {
+			add(dirExists(inst.GameDir), T_("Game directory: %s"), inst.GameDir)
+		}
+		add(dirExists(inst.Workshop), T_("Workshop content: %s"), inst.Workshop)
+	}
+
+	add(dirExists(cfg.Root), T_("workshop_root: %s"), cfg.Root)
+	if err == nil && !samePath(cfg.Root, inst.Workshop) {
+		add(false, T_("workshop_root differs from the detected Workshop directory %s"), inst.Workshop)
+	}
+
+	if cfg.GameDir != "" {
+		add(dirExists(cfg.GameDir), T_("game_dir: %s"), cfg.GameDir)
+	}
+
+	disdir := getDisabledDir(cfg)
+	add(true, T_("disabled_dir: %s"), disdir)
+	if !sameDevice(cfg.Root, disdir) {
+		add(false, "%s", T_("disabled_dir is on another filesystem, mods will be copied instead of moved"))
+	}
+
+	if l, err := NewLauncher(cfg); err != nil {
+		add(false, T_("launcher: %v"), err)
+	} else {
+		add(true, T_("launcher: %s"), l.Name())
+	}
+
+	return out
+}
+
+func PrintDiagnostics(diags []Diagnostic) {
+	for _, d := range diags {
+		mark := "✅"
+		if !d.OK {
+			mark = "❌"
+		}
+		fmt.Printf("%s %s\n", mark, d.Message)
+	}
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func samePath(a, b string) bool {
+	if ra, err := filepath.EvalSymlinks(a); err == nil {
+		a = ra
+	}
+	if rb, err := filepath.EvalSymlinks(b); err == nil {
+		b = rb
+	}
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+// sameDevice reports whether both paths live on one filesystem. The second
+// path may not exist yet, in which case its nearest existing parent is used.
+func sameDevice(a, b string) bool {
+	da, okA := deviceOf(a)
+	db, okB := deviceOf(b)
+	return !okA || !okB || da == db
+}
+
+func deviceOf(path string) (uint64, bool) {
+	for {
+		var st syscall.Stat_t
+		if err := syscall.Stat(path, &st); err == nil {
+			return uint64(st.Dev), true
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, false
+		}
+		path = parent
+	}
+}
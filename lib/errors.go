@@ -0,0 +1,132 @@
+package lib
+
+import "fmt"
+
+// ModNotFoundError is returned when no mod has the given folder or
+// codename.
+type ModNotFoundError struct {
+	ID string
+}
+
+func (e *ModNotFoundError) Error() string {
+	return fmt.Sprintf(T_("mod not found: %s"), e.ID)
+}
+
+type ProfileNotFoundError struct {
+	Name string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	return fmt.Sprintf(T_("profile not found: %s"), e.Name)
+}
+
+// MissingIDError is returned by commands that need a mod id and got none.
+type MissingIDError struct{}
+
+func (e *MissingIDError) Error() string {
+	return T_("provide folder id, codename, or ALL")
+}
+
+// BusyError is returned when a launch is started while another one runs,
+// in this process or another.
+type BusyError struct{}
+
+func (e *BusyError) Error() string {
+	return T_("the game is already being launched")
+}
+
+// Launch stages, in the order they run.
+const (
+	LaunchStageLauncher = "launcher"
+	LaunchStageHook     = "hook"
+	LaunchStageSaves    = "saves"
+	LaunchStageMods     = "mods"
+	LaunchStageGame     = "game"
+)
+
+// LaunchError is returned when a launch fails before or while the game
+// runs. Everything done up to Stage has been undone.
+type LaunchError struct {
+	Stage string
+	Err   error
+}
+
+func (e *LaunchError) Error() string {
+	switch e.Stage {
+	case LaunchStageHook:
+		return T_("launch aborted:") + " " + e.Err.Error()
+	case LaunchStageSaves:
+		return T_("error swapping saves:") + " " + e.Err.Error()
+	case LaunchStageMods:
+		return T_("error disabling mods:") + " " + e.Err.Error()
+	}
+	return T_("game launch error:") + " " + e.Err.Error()
+}
+
+func (e *LaunchError) Unwrap() error {
+	return e.Err
+}
+
+// RestoreError is returned when mods or saves could not be put back after
+// a session. The files are left where they are for manual recovery.
+type RestoreError struct {
+	Err error
+}
+
+func (e *RestoreError) Error() string {
+	return T_("restore error:") + " " + e.Err.Error()
+}
+
+func (e *RestoreError) Unwrap() error {
+	return e.Err
+}
+
+// NoManifestError is returned for mods whose files have not been recorded
+// yet.
+type NoManifestError struct {
+	Folder string
+}
+
+func (e *NoManifestError) Error() string {
+	return fmt.Sprintf(T_("no file manifest for %s yet"), e.Folder)
+}
+
+// VerifyError is returned when a copy of Dir does not match it. Dir is
+// kept and the copy is removed.
+type VerifyError struct {
+	Dir    string
+	Report *VerifyReport
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf(T_("copy of %s is incomplete: %s"), e.Dir, e.Report)
+}
+
+// NoSpaceError is returned when a copy would not fit into Dir.
+type NoSpaceError struct {
+	Dir  string
+	Need int64
+	Free int64
+}
+
+func (e *NoSpaceError) Error() string {
+	return Format(T_("not enough space in {dir}: {need} needed, {free} free"), Args{
+		"dir": e.Dir, "need": FormatSize(e.Need), "free": FormatSize(e.Free),
+	})
+}
+
+// NoBisectError is returned when a bisect step is asked for and none is in
+// progress.
+type NoBisectError struct{}
+
+func (e *NoBisectError) Error() string {
+	return T_("no bisect in progress, start one with `herbarium bisect start`")
+}
+
+// NothingToBisectError is returned when a bisect is started with no mods
+// enabled.
+type NothingToBisectError struct{}
+
+func (e *NothingToBisectError) Error() string {
+	return T_("no mods are enabled, there is nothing to bisect")
+}
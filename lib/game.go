@@ -1,184 +1,248 @@
 package lib
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"os"
 	"os/exec"
-	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 )
 
+// killGrace is how long a game asked to quit gets before it is killed.
+const killGrace = 10 * time.Second
+
 func getDisabledDir(cfg *Config) string {
-	homeDir, _ := os.UserHomeDir()
-	dir := cfg.DisabledDir
-	if dir == "" {
-		dir = filepath.Join(homeDir, ".elmod_disabled")
+	if cfg.DisabledDir == "" {
+		return CurrentGame().defaultDisabledDir()
 	}
-	return dir
+	return cfg.DisabledDir
 }
 
-func restoreMoved(pairs [][2]string) error {
-	for i := len(pairs) - 1; i >= 0; i-- {
-		src := pairs[i][1]
-		dst := pairs[i][0]
-
-		if _, err := os.Stat(dst); err == nil {
-			fmt.Println(T_("warning: destination exists, skipping restore:"), dst)
-			continue
-		}
-
-		if err := os.Rename(src, dst); err != nil {
-			if err := copyDir(src, dst); err != nil {
-				return err
-			}
-
-			if err := os.RemoveAll(src); err != nil {
-				return err
-			}
+// lockLaunch takes the launch lock of the current game, which keeps the
+// GUI, the CLI and the server from moving the same folders at once. The
+// lock is released with the file, so a process that dies leaves none
+// behind.
+func lockLaunch() (unlock func(), err error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "launch.lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &BusyError{}
 		}
+		return nil, err
 	}
-
-	return nil
+	return func() { f.Close() }, nil
 }
 
-func copyDir(src, dst string) error {
-	entries, err := os.ReadDir(src)
-	if err != nil {
-		return err
-	}
-	if err := os.MkdirAll(dst, 0755); err != nil {
-		return err
+// restoreMoved moves the folders of pairs back in reverse order, reporting
+// the copies made on the way. A folder that is already back, because Steam
+// downloaded it again, is a conflict to resolve; the conflicts are
+// returned. It does not take a context: once started, a restore is
+// finished.
+func restoreMoved(pairs [][2]string, progress func(CopyProgress)) (conflicts []RestoreConflict, err error) {
+	var back [][2]string
+	for i := len(pairs) - 1; i >= 0; i-- {
+		back = append(back, [2]string{pairs[i][1], pairs[i][0]})
 	}
-	for _, e := range entries {
-		srcPath := filepath.Join(src, e.Name())
-		dstPath := filepath.Join(dst, e.Name())
-		if e.IsDir() {
-			if err := copyDir(srcPath, dstPath); err != nil {
-				return err
+	c := newCopier(context.Background(), copyBytes(back), progress)
+	defer c.finish()
+
+	for _, m := range back {
+		src, dst := m[0], m[1]
+
+		if _, err := os.Stat(dst); err == nil {
+			conflict, err := c.resolveConflict(src, dst)
+			if conflict != nil {
+				conflicts = append(conflicts, *conflict)
 			}
-		} else {
-			data, err := os.ReadFile(srcPath)
 			if err != nil {
-				return err
-			}
-			if err := os.WriteFile(dstPath, data, 0644); err != nil {
-				return err
+				return conflicts, err
 			}
+			continue
+		}
+
+		if err := c.moveDir(src, dst); err != nil {
+			return conflicts, err
 		}
 	}
-	return nil
+
+	return conflicts, nil
 }
 
-func moveDisabledMods(db *ModsDB, cfg *Config) (moved [][2]string, err error) {
-	root := cfg.Root
-	disdir := getDisabledDir(cfg)
+// moveDisabledMods moves the folders of disabled mods aside. It stops
+// before moving anything when the copies would not fit, and returns the
+// moves made so far along with any error, for restoreMoved to undo.
+func moveDisabledMods(ctx context.Context, db *ModsDB, cfg *Config, progress func(CopyProgress)) (moved [][2]string, err error) {
+	plan := PlanLaunch(cfg, db)
+	if !plan.FitsOnDisk() {
+		return nil, &NoSpaceError{Dir: plan.Target, Need: plan.CopyBytes, Free: plan.FreeBytes}
+	}
 
-	if err := os.MkdirAll(disdir, 0755); err != nil {
+	if err := os.MkdirAll(plan.Target, 0755); err != nil {
 		return nil, err
 	}
 
-	for _, m := range db.Mods {
-		if !m.Enabled {
-			src := filepath.Join(root, m.Folder)
-			dst := filepath.Join(disdir, m.Folder)
-
-			if _, err := os.Stat(src); os.IsNotExist(err) {
-				continue
+	c := newCopier(ctx, plan.CopyBytes, progress)
+	defer c.finish()
+	for _, m := range plan.Moves {
+		if err := c.moveDir(m.Src, m.Dst); err != nil {
+			// A complete copy at dst has to be moved back as well,
+			// even if deleting the source failed half-way; the
+			// restore then replaces what is left of it.
+			if _, serr := os.Stat(m.Dst); serr == nil {
+				moved = append(moved, [2]string{m.Src, m.Dst})
 			}
-
-			if err := os.Rename(src, dst); err != nil {
-				if err := copyDir(src, dst); err != nil {
-					return moved, err
-				}
-
-				if err := os.RemoveAll(src); err != nil {
-					return moved, err
-				}
-			}
-			moved = append(moved, [2]string{src, dst})
+			return moved, err
 		}
+		moved = append(moved, [2]string{m.Src, m.Dst})
 	}
 	return moved, nil
 }
 
-func isProcessRunning(name string) (bool, error) {
+func isProcessRunning(match func(string) bool) (bool, error) {
+	pids, err := findProcesses(match)
+	return len(pids) > 0, err
+}
+
+// findProcesses returns the IDs of the processes whose `ps ax` line
+// matches.
+func findProcesses(match func(string) bool) ([]int, error) {
 	out, err := exec.Command("ps", "ax").Output()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
+	var pids []int
 	lines := strings.SplitSeq(string(out), "\n")
 	for l := range lines {
-		if strings.Contains(l, name) {
-			return true, nil
+		if !match(l) {
+			continue
+		}
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[0]); err == nil && pid != os.Getpid() {
+			pids = append(pids, pid)
 		}
 	}
-	return false, nil
+	return pids, nil
 }
 
-func launchGame(exe string, args []string) error {
-	cmd := exec.Command(exe, args...)
+// launchGame starts the game and waits until it exits or ctx is done.
+// onDetected is called once the game process is known to be running. When
+// ctx is done the game is stopped, and waited for, so that nothing is
+// moved back under it: an attached game through its command, a detached
+// one through the processes that match it.
+func launchGame(ctx context.Context, l Launcher, onStart func(command string), onDetected func()) error {
+	cmd, err := l.Command()
+	if err != nil {
+		return err
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	fmt.Println(T_("Launching via Steam:"), exe, args)
+	onStart(strings.Join(cmd.Args, " "))
 
 	if err := cmd.Start(); err != nil {
 		return err
 	}
-
-	target := "Everlasting Sum"
-
-	for {
-		running, _ := isProcessRunning(target)
-		if running {
-			break
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	if !l.Detached() {
+		onDetected()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			stopGame(cmd, done)
+			return ctx.Err()
 		}
-		time.Sleep(time.Second)
 	}
-	for {
-		running, _ := isProcessRunning(target)
-		if !running {
-			break
-		}
-		time.Sleep(time.Second)
+
+	// The command itself may be the Steam client, which is left running.
+	err = waitForProcess(ctx, l.MatchProcess, true)
+	if err == nil {
+		onDetected()
+		err = waitForProcess(ctx, l.MatchProcess, false)
 	}
-	fmt.Println(T_("Target process exited."))
-	return nil
+	if err != nil {
+		stopProcesses(l.MatchProcess)
+	}
+	return err
 }
 
-func LaunchWithMods(cfg *Config, db *ModsDB) {
-	moved, err := moveDisabledMods(db, cfg)
-	if err != nil {
-		fmt.Println(T_("error disabling mods:"), err)
-		os.Exit(1)
+// stopGame asks the process of cmd to quit, kills it if it is still
+// running after killGrace, and returns once it is gone. done receives the
+// result of cmd.Wait.
+func stopGame(cmd *exec.Cmd, done <-chan error) {
+	cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-done:
+		return
+	case <-time.After(killGrace):
 	}
+	cmd.Process.Kill()
+	<-done
+}
 
-	restore := func() {
-		if len(moved) > 0 {
-			fmt.Printf(T_("Restoring %d folders...\n"), len(moved))
-			if err := restoreMoved(moved); err != nil {
-				fmt.Println(T_("restore error:"), err)
-			}
-		}
+// stopProcesses asks the processes that match to quit, kills the ones
+// still running after killGrace, and returns once they are gone or did
+// not go within another killGrace.
+func stopProcesses(match func(string) bool) {
+	signalProcesses(match, syscall.SIGTERM)
+	if waitForExit(match, killGrace) {
+		return
 	}
+	signalProcesses(match, syscall.SIGKILL)
+	waitForExit(match, killGrace)
+}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		fmt.Println(T_("Interrupted — restoring..."))
-		restore()
-		os.Exit(1)
-	}()
+func signalProcesses(match func(string) bool, sig syscall.Signal) {
+	pids, _ := findProcesses(match)
+	for _, pid := range pids {
+		syscall.Kill(pid, sig)
+	}
+}
 
-	if err := launchGame(cfg.GameExe, cfg.Args); err != nil {
-		fmt.Println(T_("game launch error:"), err)
+// waitForExit reports whether no process matches within timeout.
+func waitForExit(match func(string) bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if found, err := isProcessRunning(match); err != nil || !found {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
+}
 
-	restore()
-	fmt.Println(T_("Game exited — mods restored."))
+// waitForProcess polls until the game process is running, or gone.
+func waitForProcess(ctx context.Context, match func(string) bool, running bool) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if found, _ := isProcessRunning(match); found == running {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultGameID is the built-in game. Its config and database stay in the
+// top-level config directory, where they were before games were added.
+const DefaultGameID = "es"
+
+// GameDefinition describes a Steam Workshop game whose mods Herbarium can
+// manage.
+type GameDefinition struct {
+	ID    string `yaml:"id"`
+	Name  string `yaml:"name"`
+	AppID string `yaml:"app_id"`
+	// ProcessName is matched against `ps ax` to find the running game.
+	ProcessName string `yaml:"process_name,omitempty"`
+	NativeExe   string `yaml:"native_exe,omitempty"`
+	WindowsExe  string `yaml:"windows_exe,omitempty"`
+	// RenpyDir is part of the ~/.renpy directory name holding the saves.
+	RenpyDir    string `yaml:"renpy_dir,omitempty"`
+	DisabledDir string `yaml:"disabled_dir,omitempty"`
+	// Extractors are regular expressions run over every line of the mod's
+	// .rpy files. The first group is the codename, the second the name.
+	Extractors []string `yaml:"extractors,omitempty"`
+
+	extractors []*regexp.Regexp
+}
+
+var everlastingSummer = GameDefinition{
+	ID:          DefaultGameID,
+	Name:        "Everlasting Summer",
+	AppID:       "331470",
+	ProcessName: "Everlasting Sum",
+	NativeExe:   "Everlasting Summer.sh",
+	WindowsExe:  "Everlasting Summer.exe",
+	RenpyDir:    "everlasting",
+	DisabledDir: "~/.elmod_disabled",
+	Extractors: []string{
+		`^\s*\$?\s*mods\s*\[\s*["']([^"'\]]+)["']\s*\]\s*=\s*u?["']([\s\S]*?)["']`,
+	},
+}
+
+var currentGame *GameDefinition
+
+// CurrentGame returns the game selected with SelectGame, or Everlasting
+// Summer.
+func CurrentGame() *GameDefinition {
+	if currentGame == nil {
+		g := everlastingSummer
+		g.compile()
+		currentGame = &g
+	}
+	return currentGame
+}
+
+// SelectGame makes the game with the given id current. An empty id selects
+// the built-in game.
+func SelectGame(id string) error {
+	if id == "" {
+		id = DefaultGameID
+	}
+	games, err := Games()
+	if err != nil {
+		return err
+	}
+	for i := range games {
+		if games[i].ID == id {
+			currentGame = &games[i]
+			return nil
+		}
+	}
+	return fmt.Errorf(T_("unknown game: %s"), id)
+}
+
+// Games returns the built-in game followed by the ones defined in
+// games.yaml. A definition with the id of a built-in one replaces it.
+func Games() ([]GameDefinition, error) {
+	games := []GameDefinition{everlastingSummer}
+
+	path, err := gamesPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var custom []GameDefinition
+	if err := yaml.Unmarshal(b, &custom); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, g := range custom {
+		if err := g.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if i := gameIndex(games, g.ID); i >= 0 {
+			games[i] = g
+		} else {
+			games = append(games, g)
+		}
+	}
+
+	for i := range games {
+		if err := games[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return games, nil
+}
+
+func gamesPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "games.yaml"), nil
+}
+
+func gameIndex(games []GameDefinition, id string) int {
+	for i := range games {
+		if games[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (g *GameDefinition) validate() error {
+	switch {
+	case g.ID == "":
+		return errors.New(T_("game definition without id"))
+	case strings.ContainsAny(g.ID, `/\. `):
+		return fmt.Errorf(T_("bad game id: %s"), g.ID)
+	case g.AppID == "":
+		return fmt.Errorf(T_("game %s has no app_id"), g.ID)
+	}
+	return nil
+}
+
+func (g *GameDefinition) compile() error {
+	g.extractors = g.extractors[:0]
+	for _, expr := range g.Extractors {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf(T_("game %s: bad extractor: %w"), g.ID, err)
+		}
+		if re.NumSubexp() < 2 {
+			return fmt.Errorf(T_("game %s: extractor needs two groups: %s"), g.ID, expr)
+		}
+		g.extractors = append(g.extractors, re)
+	}
+	return nil
+}
+
+// DisplayName falls back to the id for games defined without a name.
+func (g *GameDefinition) DisplayName() string {
+	if g.Name != "" {
+		return g.Name
+	}
+	return g.ID
+}
+
+// processName defaults to the name of the game.
+func (g *GameDefinition) processName() string {
+	if g.ProcessName != "" {
+		return g.ProcessName
+	}
+	return g.DisplayName()
+}
+
+// extract returns the codename and name registered on a script line.
+func (g *GameDefinition) extract(line string) (codename, name string, ok bool) {
+	for _, re := range g.extractors {
+		if m := re.FindStringSubmatch(line); m != nil {
+			return m[1], m[2], true
+		}
+	}
+	return "", "", false
+}
+
+func (g *GameDefinition) defaultDisabledDir() string {
+	home, _ := os.UserHomeDir()
+	dir := g.DisabledDir
+	if dir == "" {
+		return filepath.Join(home, ".herbarium_disabled", g.ID)
+	}
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		return filepath.Join(home, rest)
+	}
+	return dir
+}
+
+// namespace is the subdirectory holding the game's own config, database and
+// data; it is empty for the built-in game.
+func (g *GameDefinition) namespace() string {
+	if g.ID == DefaultGameID {
+		return ""
+	}
+	return filepath.Join("games", g.ID)
+}
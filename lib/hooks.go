@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	HookPreLaunch          = "pre_launch"
+	HookPostLaunchDetected = "post_launch_detected"
+	HookPostExit           = "post_exit"
+	HookOnRestoreFailure   = "on_restore_failure"
+)
+
+const defaultHookTimeout = 60 * time.Second
+
+// HooksConfig holds shell commands run around a play session. Each one is
+// run with `sh -c` and gets HERBARIUM_* variables describing the session.
+type HooksConfig struct {
+	PreLaunch          string `yaml:"pre_launch,omitempty"`
+	PostLaunchDetected string `yaml:"post_launch_detected,omitempty"`
+	PostExit           string `yaml:"post_exit,omitempty"`
+	OnRestoreFailure   string `yaml:"on_restore_failure,omitempty"`
+	// Timeout is in seconds; a hook running longer is killed.
+	Timeout int `yaml:"timeout,omitempty"`
+}
+
+func (h HooksConfig) command(event string) string {
+	switch event {
+	case HookPreLaunch:
+		return h.PreLaunch
+	case HookPostLaunchDetected:
+		return h.PostLaunchDetected
+	case HookPostExit:
+		return h.PostExit
+	case HookOnRestoreFailure:
+		return h.OnRestoreFailure
+	}
+	return ""
+}
+
+// hookEnv describes the session to hook commands.
+func hookEnv(cfg *Config, db *ModsDB, event string, extra map[string]string) []string {
+	var folders, codenames []string
+	for _, m := range db.Mods {
+		if m.Enabled {
+			folders = append(folders, m.Folder)
+			codenames = append(codenames, m.CodeName)
+		}
+	}
+
+	dir, _ := gameConfigDir()
+	env := []string{
+		"HERBARIUM_HOOK=" + event,
+		"HERBARIUM_GAME=" + CurrentGame().ID,
+		"HERBARIUM_APP_ID=" + CurrentGame().AppID,
+		"HERBARIUM_ENABLED_MODS=" + strings.Join(folders, ","),
+		"HERBARIUM_ENABLED_CODENAMES=" + strings.Join(codenames, ","),
+		"HERBARIUM_PROFILE=" + db.ActiveProfile,
+		"HERBARIUM_WORKSHOP_ROOT=" + cfg.Root,
+		"HERBARIUM_DISABLED_DIR=" + getDisabledDir(cfg),
+		"HERBARIUM_GAME_DIR=" + cfg.GameDir,
+		"HERBARIUM_LAUNCHER=" + cfg.Launcher,
+		"HERBARIUM_CONFIG_DIR=" + dir,
+		"HERBARIUM_SAVE_DIRS=" + strings.Join(SaveDirs(cfg), string(os.PathListSeparator)),
+	}
+	for k, v := range extra {
+		env = append(env, "HERBARIUM_"+k+"="+v)
+	}
+	return env
+}
+
+// runHook runs the command configured for event, if any. A hook that fails
+// or runs out of time returns an error.
+func runHook(cfg *Config, db *ModsDB, event string, extra map[string]string) error {
+	command := cfg.Hooks.command(event)
+	if command == "" {
+		return nil
+	}
+
+	timeout := defaultHookTimeout
+	if cfg.Hooks.Timeout > 0 {
+		timeout = time.Duration(cfg.Hooks.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), hookEnv(cfg, db, event, extra)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.WaitDelay = time.Second
+
+	slog.Info(T_("Running hook"), "hook", event)
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf(T_("hook %s timed out after %s"), event, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf(T_("hook %s failed: %w"), event, err)
+	}
+	return nil
+}
@@ -2,14 +2,41 @@
 package lib
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+)
 
-	gcore "github.com/diamondburned/gotk4/pkg/core/glib"
+const (
+	textDomain = "herbarium"
+	LocaleDir  = "/usr/share/locale"
 )
 
+// Translator looks up translated messages. The CLI uses a Catalog read in
+// pure Go; the GUI installs one backed by GLib's gettext.
+type Translator interface {
+	Gettext(msgid string) string
+	NGettext(msgid, plural string, n int) string
+	PGettext(context, msgid string) string
+	NPGettext(context, msgid, plural string, n int) string
+}
+
+var translator Translator = (*Catalog)(nil)
+
+func SetTranslator(t Translator) {
+	translator = t
+}
+
+// InitLocales loads the catalog of the system locale, if there is one.
 func InitLocales() {
-	gcore.InitI18n("herbarium", "/usr/share/locale")
+	for _, name := range localeCandidates(GetSystemLocale()) {
+		path := filepath.Join(LocaleDir, name, "LC_MESSAGES", textDomain+".mo")
+		if c, err := LoadCatalog(path); err == nil {
+			SetTranslator(c)
+			return
+		}
+	}
 }
 
 func GetSystemLocale() string {
@@ -32,6 +59,72 @@ func stripLocaleEncoding(locale string) string {
 	return locale
 }
 
+// localeCandidates returns the catalog directories to try for a locale,
+// from the most specific: "ru_RU@mod", "ru_RU", "ru".
+func localeCandidates(locale string) []string {
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+
+	var out []string
+	base, _, hasModifier := strings.Cut(locale, "@")
+	if hasModifier {
+		out = append(out, locale)
+	}
+	out = append(out, base)
+	if lang, _, ok := strings.Cut(base, "_"); ok {
+		out = append(out, lang)
+	}
+	return out
+}
+
 func T_(messageID string) string {
-	return gcore.Local(messageID)
+	return translator.Gettext(messageID)
+}
+
+// N_ translates a message whose wording depends on the count n.
+func N_(messageID, plural string, n int) string {
+	return translator.NGettext(messageID, plural, n)
+}
+
+// PT_ translates a message in a context, for short strings that read
+// differently in different places.
+func PT_(context, messageID string) string {
+	return translator.PGettext(context, messageID)
+}
+
+// NT_ is N_ with a context.
+func NT_(context, messageID, plural string, n int) string {
+	return translator.NPGettext(context, messageID, plural, n)
+}
+
+// Args holds the values of named placeholders for Format.
+type Args map[string]any
+
+// Format replaces {name} placeholders with values from args. Unlike
+// Printf verbs, named placeholders can be reordered by translators.
+// Unknown placeholders are left as they are.
+func Format(message string, args Args) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(message, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(message[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		b.WriteString(message[:start])
+		if v, ok := args[message[start+1:end]]; ok {
+			fmt.Fprint(&b, v)
+		} else {
+			b.WriteString(message[start : end+1])
+		}
+		message = message[end+1:]
+	}
+	b.WriteString(message)
+	return b.String()
 }
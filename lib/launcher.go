@@ -0,0 +1,244 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const flatpakSteamID = "com.valvesoftware.Steam"
+
+const (
+	LauncherSteam        = "steam"
+	LauncherFlatpakSteam = "flatpak-steam"
+	LauncherNative       = "native"
+	LauncherWine         = "wine"
+	LauncherProton       = "proton"
+	LauncherCustom       = "custom"
+)
+
+// Launcher starts the game and knows how to recognise its process.
+type Launcher interface {
+	Name() string
+	Command() (*exec.Cmd, error)
+	// Detached reports whether the started command hands the game over to
+	// another process (e.g. the Steam client) and may exit before the game
+	// does. For attached launchers the command itself is the game.
+	Detached() bool
+	// MatchProcess reports whether a line of `ps ax` belongs to the game.
+	MatchProcess(line string) bool
+}
+
+func NewLauncher(cfg *Config) (Launcher, error) {
+	match := processMatcher(cfg.ProcessName)
+
+	launcher := cfg.Launcher
+	if launcher == "" {
+		launcher = legacyLauncher(cfg)
+	}
+
+	switch launcher {
+	case LauncherSteam:
+		exe := cfg.GameExe
+		if exe == "" {
+			exe = "steam"
+		}
+		return &steamLauncher{exe: exe, args: withoutAppLaunch(cfg.Args), match: match}, nil
+
+	case LauncherFlatpakSteam:
+		return &steamLauncher{
+			exe:     "flatpak",
+			prefix:  []string{"run", flatpakSteamID},
+			args:    withoutAppLaunch(cfg.Args),
+			match:   match,
+			flatpak: true,
+		}, nil
+
+	case LauncherNative:
+		exe := cfg.GameExe
+		if exe == "" {
+			if cfg.GameDir == "" {
+				return nil, errors.New(T_("game_exe or game_dir must be set for the native launcher"))
+			}
+			if CurrentGame().NativeExe == "" {
+				return nil, errors.New(T_("game_exe must be set for the native launcher"))
+			}
+			exe = filepath.Join(cfg.GameDir, CurrentGame().NativeExe)
+		}
+		return &directLauncher{name: LauncherNative, exe: exe, args: cfg.Args, dir: cfg.GameDir, match: match}, nil
+
+	case LauncherWine, LauncherProton:
+		return newWineLauncher(cfg, match)
+
+	case LauncherCustom:
+		if cfg.GameExe == "" {
+			return nil, errors.New(T_("game_exe is empty in config"))
+		}
+		return &customLauncher{exe: cfg.GameExe, args: cfg.Args, match: match}, nil
+	}
+
+	return nil, fmt.Errorf(T_("unknown launcher: %s"), cfg.Launcher)
+}
+
+// legacyLauncher picks the launcher for a config written before there was
+// a launcher setting, when game_exe and args were run as they are: the
+// Steam one if they ask Steam to launch the game, custom otherwise.
+func legacyLauncher(cfg *Config) string {
+	if filepath.Base(cfg.GameExe) == "steam" && len(withoutAppLaunch(cfg.Args)) < len(cfg.Args) {
+		return LauncherSteam
+	}
+	return LauncherCustom
+}
+
+// withoutAppLaunch drops "-applaunch <app id>" of the current game from
+// args, since the Steam launchers add it themselves.
+func withoutAppLaunch(args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-applaunch" && i+1 < len(args) && args[i+1] == CurrentGame().AppID {
+			i++
+			continue
+		}
+		out = append(out, args[i])
+	}
+	return out
+}
+
+func processMatcher(name string) func(string) bool {
+	if name == "" {
+		name = CurrentGame().processName()
+	}
+	return func(line string) bool {
+		return strings.Contains(line, name)
+	}
+}
+
+// steamLauncher asks a native or Flatpak Steam client to start the game.
+type steamLauncher struct {
+	exe     string
+	prefix  []string
+	args    []string
+	match   func(string) bool
+	flatpak bool
+}
+
+func (l *steamLauncher) Name() string {
+	if l.flatpak {
+		return LauncherFlatpakSteam
+	}
+	return LauncherSteam
+}
+
+func (l *steamLauncher) Command() (*exec.Cmd, error) {
+	args := append([]string{}, l.prefix...)
+	args = append(args, "-applaunch", CurrentGame().AppID)
+	args = append(args, l.args...)
+	return exec.Command(l.exe, args...), nil
+}
+
+func (l *steamLauncher) Detached() bool             { return true }
+func (l *steamLauncher) MatchProcess(s string) bool { return l.match(s) }
+
+// directLauncher runs the game binary itself, so the child is the game.
+type directLauncher struct {
+	name  string
+	exe   string
+	args  []string
+	dir   string
+	env   []string
+	match func(string) bool
+}
+
+func (l *directLauncher) Name() string { return l.name }
+
+func (l *directLauncher) Command() (*exec.Cmd, error) {
+	if _, err := os.Stat(l.exe); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(l.exe, l.args...)
+	cmd.Dir = l.dir
+	if len(l.env) > 0 {
+		cmd.Env = append(os.Environ(), l.env...)
+	}
+	return cmd, nil
+}
+
+func (l *directLauncher) Detached() bool             { return false }
+func (l *directLauncher) MatchProcess(s string) bool { return l.match(s) }
+
+func newWineLauncher(cfg *Config, match func(string) bool) (Launcher, error) {
+	game := cfg.GameExe
+	if game == "" {
+		if cfg.GameDir == "" {
+			return nil, fmt.Errorf(T_("game_exe or game_dir must be set for the %s launcher"), cfg.Launcher)
+		}
+		if CurrentGame().WindowsExe == "" {
+			return nil, fmt.Errorf(T_("game_exe must be set for the %s launcher"), cfg.Launcher)
+		}
+		game = filepath.Join(cfg.GameDir, CurrentGame().WindowsExe)
+	}
+
+	dir := cfg.GameDir
+	if dir == "" {
+		dir = filepath.Dir(game)
+	}
+
+	l := &directLauncher{name: cfg.Launcher, dir: dir, match: match}
+
+	if cfg.Launcher == LauncherProton {
+		if cfg.Runner == "" {
+			return nil, errors.New(T_("runner must point to a Proton installation"))
+		}
+		// Proton refuses to start without a compat data directory.
+		if cfg.WinePrefix == "" {
+			return nil, errors.New(T_("wine_prefix must be set for the proton launcher"))
+		}
+		home, _ := os.UserHomeDir()
+		l.exe = cfg.Runner
+		if info, err := os.Stat(cfg.Runner); err == nil && info.IsDir() {
+			l.exe = filepath.Join(cfg.Runner, "proton")
+		}
+		l.args = append([]string{"run", game}, cfg.Args...)
+		l.env = []string{
+			"STEAM_COMPAT_CLIENT_INSTALL_PATH=" + filepath.Join(home, ".steam/steam"),
+			"SteamAppId=" + CurrentGame().AppID,
+			"STEAM_COMPAT_DATA_PATH=" + cfg.WinePrefix,
+		}
+		return l, nil
+	}
+
+	runner := cfg.Runner
+	if runner == "" {
+		path, err := exec.LookPath("wine")
+		if err != nil {
+			return nil, err
+		}
+		runner = path
+	}
+	l.exe = runner
+	l.args = append([]string{game}, cfg.Args...)
+	if cfg.WinePrefix != "" {
+		l.env = []string{"WINEPREFIX=" + cfg.WinePrefix}
+	}
+	return l, nil
+}
+
+// customLauncher runs an arbitrary user command. Since nothing is known
+// about it, the game is tracked only by its process name.
+type customLauncher struct {
+	exe   string
+	args  []string
+	match func(string) bool
+}
+
+func (l *customLauncher) Name() string { return LauncherCustom }
+
+func (l *customLauncher) Command() (*exec.Cmd, error) {
+	return exec.Command(l.exe, l.args...), nil
+}
+
+func (l *customLauncher) Detached() bool             { return true }
+func (l *customLauncher) MatchProcess(s string) bool { return l.match(s) }
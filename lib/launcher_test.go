@@ -0,0 +1,219 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubGame writes a script that prints its arguments, standing in for the
+// game or its runner.
+func stubGame(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "game.sh")
+	script := "#!/bin/sh\necho \"$@\"\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runLauncher(t *testing.T, l Launcher) string {
+	t.Helper()
+	cmd, err := l.Command()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestDirectLauncher(t *testing.T) {
+	exe := stubGame(t)
+	cfg := &Config{Launcher: LauncherNative, GameExe: exe, GameDir: filepath.Dir(exe), Args: []string{"--windowed"}, ProcessName: "game.sh"}
+
+	l, err := NewLauncher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Detached() {
+		t.Error("native launcher is detached")
+	}
+	if got := runLauncher(t, l); got != "--windowed" {
+		t.Errorf("output = %q", got)
+	}
+	if !l.MatchProcess("1234 ?  S  0:01 /games/game.sh --windowed") || l.MatchProcess("1235 ? S 0:00 bash") {
+		t.Error("MatchProcess does not follow process_name")
+	}
+
+	cfg.GameExe = filepath.Join(t.TempDir(), "missing")
+	l, err = NewLauncher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Command(); err == nil {
+		t.Error("Command succeeded for a missing executable")
+	}
+}
+
+func TestCustomLauncher(t *testing.T) {
+	exe := stubGame(t)
+	l, err := NewLauncher(&Config{Launcher: LauncherCustom, GameExe: exe, Args: []string{"a", "b"}, ProcessName: "Game.exe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Detached() {
+		t.Error("custom launcher is attached")
+	}
+	if got := runLauncher(t, l); got != "a b" {
+		t.Errorf("output = %q", got)
+	}
+	if !l.MatchProcess("99 ? Sl 1:00 Z:\\games\\Game.exe") {
+		t.Error("MatchProcess missed the game")
+	}
+
+	if _, err := NewLauncher(&Config{Launcher: LauncherCustom}); err == nil {
+		t.Error("custom launcher without game_exe")
+	}
+}
+
+func TestWineLauncher(t *testing.T) {
+	runner := stubGame(t)
+	l, err := NewLauncher(&Config{Launcher: LauncherWine, GameExe: "C:/Game.exe", GameDir: t.TempDir(), Runner: runner, WinePrefix: "/prefix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := runLauncher(t, l); got != "C:/Game.exe" {
+		t.Errorf("output = %q", got)
+	}
+	cmd, _ := l.Command()
+	if !slices.Contains(cmd.Env, "WINEPREFIX=/prefix") {
+		t.Error("WINEPREFIX is not set")
+	}
+}
+
+func TestProtonLauncher(t *testing.T) {
+	runner := stubGame(t)
+	cfg := &Config{Launcher: LauncherProton, GameExe: "/games/Game.exe", GameDir: t.TempDir(), Runner: runner, WinePrefix: "/compat"}
+	l, err := NewLauncher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := runLauncher(t, l); got != "run /games/Game.exe" {
+		t.Errorf("output = %q", got)
+	}
+	cmd, _ := l.Command()
+	if !slices.Contains(cmd.Env, "STEAM_COMPAT_DATA_PATH=/compat") {
+		t.Error("STEAM_COMPAT_DATA_PATH is not set")
+	}
+
+	cfg.WinePrefix = ""
+	if _, err := NewLauncher(cfg); err == nil {
+		t.Error("proton launcher without wine_prefix")
+	}
+	cfg.WinePrefix, cfg.Runner = "/compat", ""
+	if _, err := NewLauncher(cfg); err == nil {
+		t.Error("proton launcher without runner")
+	}
+}
+
+func TestLaunchGameStopsAttachedGame(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "game.sh")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\nexec sleep 30\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewLauncher(&Config{Launcher: LauncherNative, GameExe: exe, GameDir: filepath.Dir(exe)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// launchGame only returns once the game is gone, so returning at
+	// all means it was stopped.
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	err = launchGame(ctx, l, func(string) {}, cancel)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+	if time.Since(start) > killGrace {
+		t.Error("the game was not asked to quit")
+	}
+}
+
+func TestLaunchGameStopsDetachedGame(t *testing.T) {
+	// The launcher starts the game in the background and exits, as Steam
+	// does; the game is only known by its process name.
+	name := fmt.Sprintf("herbarium-test-game-%d", time.Now().UnixNano())
+	game := filepath.Join(t.TempDir(), name)
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip(err)
+	}
+	if err := os.Symlink(sleep, game); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(t.TempDir(), "launch.sh")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\n"+game+" 30 &\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewLauncher(&Config{Launcher: LauncherCustom, GameExe: exe, ProcessName: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = launchGame(ctx, l, func(string) {}, cancel)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+	if running, _ := isProcessRunning(l.MatchProcess); running {
+		t.Error("the game is still running")
+	}
+}
+
+func TestSteamLauncherArgs(t *testing.T) {
+	app := CurrentGame().AppID
+	l, err := NewLauncher(&Config{Launcher: LauncherSteam, GameExe: "/usr/bin/steam", Args: []string{"-applaunch", app, "-silent"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, _ := l.Command()
+	if want := []string{"/usr/bin/steam", "-applaunch", app, "-silent"}; !slices.Equal(cmd.Args, want) {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+}
+
+func TestLegacyConfig(t *testing.T) {
+	app := CurrentGame().AppID
+	for _, tt := range []struct {
+		name     string
+		cfg      Config
+		launcher string
+		args     []string
+	}{
+		// The config older versions wrote on first run.
+		{"steam", Config{GameExe: "/usr/bin/steam", Args: []string{"-applaunch", app}}, LauncherSteam, []string{"/usr/bin/steam", "-applaunch", app}},
+		{"steam with options", Config{GameExe: "steam", Args: []string{"-applaunch", app, "-windowed"}}, LauncherSteam, []string{"steam", "-applaunch", app, "-windowed"}},
+		{"another app", Config{GameExe: "/usr/bin/steam", Args: []string{"-applaunch", "1"}}, LauncherCustom, []string{"/usr/bin/steam", "-applaunch", "1"}},
+		{"script", Config{GameExe: "/games/run.sh", Args: []string{"-applaunch", app}}, LauncherCustom, []string{"/games/run.sh", "-applaunch", app}},
+	} {
+		l, err := NewLauncher(&tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		cmd, _ := l.Command()
+		if l.Name() != tt.launcher || !slices.Equal(cmd.Args, tt.args) {
+			t.Errorf("%s: %s %q, want %s %q", tt.name, l.Name(), cmd.Args, tt.launcher, tt.args)
+		}
+	}
+}
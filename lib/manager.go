@@ -0,0 +1,550 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+type EventKind int
+
+const (
+	// EventInfo is a progress message without further meaning.
+	EventInfo EventKind = iota
+	// EventLaunching is sent when the launcher command starts; Message
+	// holds the command line.
+	EventLaunching
+	// EventGameRunning is sent once the game process has been seen.
+	EventGameRunning
+	// EventGameExited is sent when the game is gone; Err holds its exit
+	// error, if any.
+	EventGameExited
+	// EventRestored is sent when mods and saves are back in place.
+	EventRestored
+	// EventScanning reports the progress of a scan in Progress.
+	EventScanning
+	// EventCopying reports the progress of folders copied across
+	// filesystems in Copy.
+	EventCopying
+	// EventConflict is sent for each restore conflict, described in
+	// Message.
+	EventConflict
+	// EventCrashed is sent after EventGameExited when the game crashed;
+	// Crash holds the report.
+	EventCrashed
+)
+
+// Event reports the progress of a long operation.
+type Event struct {
+	Kind     EventKind
+	Message  string
+	Err      error
+	Progress ScanProgress
+	Copy     CopyProgress
+	Crash    *CrashReport
+}
+
+// Manager owns the config and mods database of the current game. It
+// reports progress through OnEvent and the log and returns errors instead
+// of printing them, so that the CLI and the GUI are front-ends to it.
+type Manager struct {
+	Config *Config
+	DB     *ModsDB
+
+	// OnEvent, if set, receives progress events. It is called from the
+	// goroutine running the operation.
+	OnEvent func(Event)
+
+	mu        sync.Mutex
+	launching bool
+}
+
+func NewManager(cfg *Config, db *ModsDB) *Manager {
+	return &Manager{Config: cfg, DB: db}
+}
+
+// OpenManager loads the config and database of the current game, creating
+// them on first run.
+func OpenManager() (*Manager, error) {
+	cfg, err := EnsureConfig()
+	if err != nil {
+		return nil, err
+	}
+	db, err := EnsureModsDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewManager(cfg, db), nil
+}
+
+func (m *Manager) emit(kind EventKind, message string, err error) {
+	if err != nil {
+		slog.Error(message, "err", err)
+	} else if message != "" {
+		slog.Info(message)
+	}
+	if m.OnEvent != nil {
+		m.OnEvent(Event{Kind: kind, Message: message, Err: err})
+	}
+}
+
+// Reload reads the database again, picking up changes made by another
+// process.
+func (m *Manager) Reload() error {
+	db, err := EnsureModsDB()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.DB = db
+	m.mu.Unlock()
+	return nil
+}
+
+// Scan brings the database up to date with the Workshop folder, sending
+// EventScanning as it goes; full reads every mod again. It works on a copy,
+// so the database stays usable while Steam is asked for titles, and merges
+// the result back, keeping edits made in the meantime; a cancelled scan
+// changes nothing.
+func (m *Manager) Scan(ctx context.Context, full bool) error {
+	m.mu.Lock()
+	db := *m.DB
+	db.Mods = slices.Clone(m.DB.Mods)
+	m.mu.Unlock()
+
+	err := ScanAndUpdate(ctx, m.Config, &db, full, func(p ScanProgress) {
+		if m.OnEvent != nil {
+			m.OnEvent(Event{Kind: EventScanning, Progress: p})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	mergeScan(m.DB, db.Mods)
+	m.mu.Unlock()
+	return nil
+}
+
+// mergeScan brings db up to date with the mods found by a scan of a copy
+// of it. Only the fields scans own are taken: Enabled and Overrides may
+// have been changed while the scan ran and are kept. The entries of mods
+// that are still there are updated in place, unless folders were added
+// or removed.
+func mergeScan(db *ModsDB, scanned []ModEntry) {
+	fresh := make(map[string]*ModEntry, len(scanned))
+	for i := range scanned {
+		fresh[scanned[i].Folder] = &scanned[i]
+	}
+
+	known := make(map[string]bool, len(db.Mods))
+	removed := false
+	for i := range db.Mods {
+		mod := &db.Mods[i]
+		known[mod.Folder] = true
+		f, ok := fresh[mod.Folder]
+		if !ok {
+			removed = true
+			continue
+		}
+		mod.Name, mod.CodeName, mod.Fingerprint = f.Name, f.CodeName, f.Fingerprint
+		mod.Size, mod.UpdatedAt = f.Size, f.UpdatedAt
+		mod.NeedsUpdate, mod.Unsubscribed = f.NeedsUpdate, f.Unsubscribed
+	}
+
+	var added []ModEntry
+	for _, f := range scanned {
+		if !known[f.Folder] {
+			added = append(added, f)
+		}
+	}
+	if !removed && len(added) == 0 {
+		return
+	}
+
+	mods := make([]ModEntry, 0, len(scanned))
+	for _, mod := range db.Mods {
+		if fresh[mod.Folder] != nil {
+			mods = append(mods, mod)
+		}
+	}
+	db.Mods = append(mods, added...)
+}
+
+func (m *Manager) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return SaveModsDB(m.DB)
+}
+
+// Mods returns a copy of the mods in the database.
+func (m *Manager) Mods() []ModEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.DB.Mods)
+}
+
+// Profiles returns a copy of the saved profiles and the name of the active
+// one.
+func (m *Manager) Profiles() ([]Profile, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.DB.Profiles), m.DB.ActiveProfile
+}
+
+// SetEnabled enables or disables the mod with the given folder or
+// codename, or every mod for "ALL", and saves the database.
+func (m *Manager) SetEnabled(id string, enabled bool) error {
+	if id == "" {
+		return &MissingIDError{}
+	}
+
+	err := m.edit(func(db *ModsDB) error {
+		if id == "ALL" {
+			setAllEnabled(db, enabled)
+			return nil
+		}
+		return setEnabled(db, id, enabled)
+	})
+	if err != nil {
+		return err
+	}
+
+	action := map[bool]string{true: "Enabled", false: "Disabled"}[enabled]
+	slog.Info(action + " " + id)
+	return nil
+}
+
+// Rename sets the name shown for a mod; an empty name goes back to the
+// extracted one.
+func (m *Manager) Rename(id, name string) error {
+	return m.edit(func(db *ModsDB) error { return renameMod(db, id, name) })
+}
+
+// SetAlias sets another codename the mod can be named by.
+func (m *Manager) SetAlias(id, alias string) error {
+	return m.edit(func(db *ModsDB) error { return setModAlias(db, id, alias) })
+}
+
+// SetCover uses a copy of image as the cover of a mod; an empty image goes
+// back to the Workshop preview.
+func (m *Manager) SetCover(id, image string) error {
+	return m.edit(func(db *ModsDB) error { return setModCover(db, id, image) })
+}
+
+// edit changes the database under the lock and saves it. If the change or
+// the save fails, the database in memory is put back as it was, keeping
+// the entries in place for the pointers the GUI holds.
+func (m *Manager) edit(change func(*ModsDB) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mods := slices.Clone(m.DB.Mods)
+	profiles, active := slices.Clone(m.DB.Profiles), m.DB.ActiveProfile
+	err := change(m.DB)
+	if err == nil {
+		err = SaveModsDB(m.DB)
+	}
+	if err != nil {
+		copy(m.DB.Mods, mods)
+		m.DB.Profiles, m.DB.ActiveProfile = profiles, active
+	}
+	return err
+}
+
+// ApplyProfile enables exactly the mods of a profile and saves the
+// database.
+func (m *Manager) ApplyProfile(name string) error {
+	return m.edit(func(db *ModsDB) error { return ApplyProfile(db, name) })
+}
+
+// ModVerification is the result of checking one mod against its manifest.
+// Report is nil when Err is set or when the manifest was only Recorded.
+type ModVerification struct {
+	Mod      ModEntry
+	Report   *VerifyReport
+	Recorded bool
+	Err      error
+}
+
+// Verify checks the files of the mod named by id, or of every mod for
+// "ALL", against their manifests. Mods without a manifest for their
+// current update, such as those found before manifests existed, have one
+// recorded instead.
+func (m *Manager) Verify(ctx context.Context, id string) ([]ModVerification, error) {
+	m.mu.Lock()
+	var mods []ModEntry
+	if id == "ALL" {
+		mods = slices.Clone(m.DB.Mods)
+	} else if mod, err := findMod(m.DB, id); err == nil {
+		mods = []ModEntry{*mod}
+	} else {
+		m.mu.Unlock()
+		return nil, err
+	}
+	root := m.Config.Root
+	m.mu.Unlock()
+
+	var results []ModVerification
+	for _, mod := range mods {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		res := ModVerification{Mod: mod}
+		manifest, err := LoadManifest(mod.Folder)
+		if errors.As(err, new(*NoManifestError)) || (err == nil && !manifest.UpdatedAt.Equal(mod.UpdatedAt)) {
+			_, err = recordManifest(ctx, root, mod)
+			res.Recorded = err == nil
+		} else if err == nil {
+			res.Report, err = manifest.Verify(ctx, filepath.Join(root, mod.Folder))
+		}
+		res.Err = err
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// StartBisect starts searching the enabled mods for the ones that crash
+// the game, replacing any bisect in progress.
+func (m *Manager) StartBisect() (*Bisect, error) {
+	m.mu.Lock()
+	mods := EnabledFolders(m.DB)
+	m.mu.Unlock()
+
+	if len(mods) == 0 {
+		return nil, &NothingToBisectError{}
+	}
+	b := newBisect(mods)
+	return b, saveBisect(b)
+}
+
+// MarkBisect records whether the game crashed in the current bisect step.
+func (m *Manager) MarkBisect(crashed bool) (*Bisect, error) {
+	b, err := LoadBisect()
+	if err != nil {
+		return nil, err
+	}
+	b.Mark(crashed)
+	return b, saveBisect(b)
+}
+
+// SkipBisect leaves the current bisect step undecided.
+func (m *Manager) SkipBisect() (*Bisect, error) {
+	b, err := LoadBisect()
+	if err != nil {
+		return nil, err
+	}
+	b.Skip()
+	return b, saveBisect(b)
+}
+
+// selection returns the database to launch with: a copy with exactly the
+// given folders enabled, or with the saved flags when selection is nil.
+func (m *Manager) selection(selection []string) *ModsDB {
+	if selection == nil {
+		return WithEnabledSet(m.DB, EnabledFolders(m.DB))
+	}
+	return WithEnabledSet(m.DB, selection)
+}
+
+// Plan describes the folder moves a launch with the given selection would
+// make. See Launch for the meaning of selection.
+func (m *Manager) Plan(selection []string) *MovePlan {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return PlanLaunch(m.Config, m.selection(selection))
+}
+
+// Launch moves the disabled mods aside, runs the game and puts everything
+// back once it exits or ctx is done. A nil selection launches with the
+// saved Enabled flags; otherwise exactly the given folders are enabled for
+// this launch only. It returns a BusyError while another launch of the
+// game runs, in this process or another.
+func (m *Manager) Launch(ctx context.Context, selection []string) error {
+	m.mu.Lock()
+	if m.launching {
+		m.mu.Unlock()
+		return &BusyError{}
+	}
+	m.launching = true
+	cfg, db := m.Config, m.selection(selection)
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.launching = false
+		m.mu.Unlock()
+	}()
+
+	unlock, err := lockLaunch()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return m.launch(ctx, cfg, db)
+}
+
+// Busy reports whether the game is being launched, by this manager or by
+// another process.
+func (m *Manager) Busy() bool {
+	m.mu.Lock()
+	launching := m.launching
+	m.mu.Unlock()
+	if launching {
+		return true
+	}
+
+	unlock, err := lockLaunch()
+	if err != nil {
+		return errors.As(err, new(*BusyError))
+	}
+	unlock()
+	return false
+}
+
+func (m *Manager) launch(ctx context.Context, cfg *Config, db *ModsDB) error {
+	launcher, err := NewLauncher(cfg)
+	if err != nil {
+		return &LaunchError{Stage: LaunchStageLauncher, Err: err}
+	}
+
+	if err := runHook(cfg, db, HookPreLaunch, nil); err != nil {
+		return &LaunchError{Stage: LaunchStageHook, Err: err}
+	}
+
+	if recovered, err := RecoverSaves(); err != nil {
+		return &LaunchError{Stage: LaunchStageSaves, Err: err}
+	} else if recovered {
+		m.emit(EventInfo, T_("Saves from an interrupted session were put back."), nil)
+	}
+
+	saves, err := swapInSaves(cfg, db)
+	if err != nil {
+		if rerr := swapOutSaves(saves); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		return &LaunchError{Stage: LaunchStageSaves, Err: err}
+	}
+	if saves != nil {
+		m.emit(EventInfo, T_("Using saves of")+" "+saves.Slot, nil)
+	}
+
+	moved, err := moveDisabledMods(ctx, db, cfg, m.copyProgress)
+	if err != nil {
+		if rerr := m.restoreMoved(moved); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		if rerr := swapOutSaves(saves); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		return &LaunchError{Stage: LaunchStageMods, Err: err}
+	}
+
+	onStart := func(command string) {
+		m.emit(EventLaunching, fmt.Sprintf(T_("Launching via %s:"), launcher.Name())+" "+command, nil)
+	}
+	onDetected := func() {
+		m.emit(EventGameRunning, "", nil)
+		if err := runHook(cfg, db, HookPostLaunchDetected, nil); err != nil {
+			slog.Error(err.Error())
+		}
+	}
+
+	started := time.Now()
+	gameErr := launchGame(ctx, launcher, onStart, onDetected)
+	if ctx.Err() != nil {
+		m.emit(EventInfo, T_("Interrupted — restoring..."), nil)
+	} else {
+		m.emit(EventGameExited, T_("Target process exited."), gameErr)
+		// Before the restore, which may swap a traceback in the save
+		// directories away.
+		m.reportCrash(cfg, db, started, gameErr)
+	}
+
+	restoreErr := m.restore(cfg, db, moved, saves)
+
+	exitStatus := "0"
+	if gameErr != nil {
+		exitStatus = gameErr.Error()
+	}
+	if err := runHook(cfg, db, HookPostExit, map[string]string{"EXIT_STATUS": exitStatus}); err != nil {
+		slog.Error(err.Error())
+	}
+
+	if restoreErr != nil {
+		return restoreErr
+	}
+	if gameErr != nil {
+		return &LaunchError{Stage: LaunchStageGame, Err: gameErr}
+	}
+	return nil
+}
+
+func (m *Manager) copyProgress(p CopyProgress) {
+	if m.OnEvent != nil {
+		m.OnEvent(Event{Kind: EventCopying, Copy: p})
+	}
+}
+
+// reportCrash records and sends a crash report if the session that
+// started at started crashed.
+func (m *Manager) reportCrash(cfg *Config, db *ModsDB, started time.Time, gameErr error) {
+	report := detectCrash(cfg, db, started, gameErr)
+	if report == nil {
+		return
+	}
+	if err := saveCrashReport(report); err != nil {
+		slog.Error(T_("cannot save the crash report"), "err", err)
+	}
+	slog.Warn(report.Summary())
+	if m.OnEvent != nil {
+		m.OnEvent(Event{Kind: EventCrashed, Message: report.Summary(), Crash: report})
+	}
+}
+
+// restoreMoved puts the moved mods back and reports the conflicts found on
+// the way.
+func (m *Manager) restoreMoved(moved [][2]string) error {
+	conflicts, err := restoreMoved(moved, m.copyProgress)
+	for _, c := range conflicts {
+		if m.OnEvent != nil {
+			m.OnEvent(Event{Kind: EventConflict, Message: c.String()})
+		}
+		slog.Warn(c.String())
+	}
+	return err
+}
+
+// restore puts the moved mods and the live saves back, running the
+// on_restore_failure hook if that fails.
+func (m *Manager) restore(cfg *Config, db *ModsDB, moved [][2]string, saves *saveSwap) error {
+	if len(moved) > 0 {
+		m.emit(EventInfo, Format(N_("Restoring {count} folder...", "Restoring {count} folders...", len(moved)),
+			Args{"count": len(moved)}), nil)
+	}
+
+	var errs []error
+	if err := m.restoreMoved(moved); err != nil {
+		errs = append(errs, err)
+	}
+	if err := swapOutSaves(saves); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		if hookErr := runHook(cfg, db, HookOnRestoreFailure, map[string]string{"ERROR": err.Error()}); hookErr != nil {
+			slog.Error(hookErr.Error())
+		}
+		return &RestoreError{Err: err}
+	}
+
+	m.emit(EventRestored, T_("Game exited — mods restored."), nil)
+	return nil
+}
@@ -0,0 +1,35 @@
+package lib
+
+import "testing"
+
+func TestMergeScanKeepsEdits(t *testing.T) {
+	db := &ModsDB{Mods: []ModEntry{
+		{Folder: "1", Name: "old", Enabled: true},
+		{Folder: "2", Name: "two"},
+	}}
+	first := &db.Mods[0]
+
+	// The scan ran on a copy while the first mod was disabled and renamed.
+	scanned := []ModEntry{
+		{Folder: "1", Name: "new", Enabled: true, Size: 10},
+		{Folder: "2", Name: "two", Size: 20},
+	}
+	first.Enabled = false
+	first.Overrides.Name = "mine"
+
+	mergeScan(db, scanned)
+	if first != &db.Mods[0] {
+		t.Fatal("entries moved without folders changing")
+	}
+	if first.Enabled || first.Overrides.Name != "mine" {
+		t.Errorf("edits lost: %+v", *first)
+	}
+	if first.Name != "new" || first.Size != 10 {
+		t.Errorf("scan result not taken: %+v", *first)
+	}
+
+	mergeScan(db, []ModEntry{{Folder: "2", Name: "two"}, {Folder: "3", Name: "three", Enabled: true}})
+	if len(db.Mods) != 2 || db.Mods[0].Folder != "2" || db.Mods[1].Folder != "3" {
+		t.Errorf("mods = %+v", db.Mods)
+	}
+}
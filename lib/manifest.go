@@ -0,0 +1,292 @@
+package lib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Manifest lists the files of a mod folder with their sizes and hashes, to
+// tell a complete folder from a partial or damaged one. UpdatedAt is the
+// Workshop update time of the mod the files were recorded at.
+type Manifest struct {
+	Folder    string         `json:"folder"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at,omitzero"`
+	Files     []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a regular file with its size and hash, or a symlink with
+// its target in Link.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Link   string `json:"link,omitempty"`
+}
+
+// VerifyReport lists the differences between a folder and its manifest, by
+// relative path.
+type VerifyReport struct {
+	Missing  []string
+	Extra    []string
+	Modified []string
+}
+
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Modified) == 0
+}
+
+func (r *VerifyReport) String() string {
+	return Format(T_("{missing} missing, {extra} extra, {modified} modified"), Args{
+		"missing": len(r.Missing), "extra": len(r.Extra), "modified": len(r.Modified),
+	})
+}
+
+// BuildManifest hashes every file under dir.
+func BuildManifest(ctx context.Context, dir string) (*Manifest, error) {
+	m := &Manifest{Folder: filepath.Base(dir), CreatedAt: time.Now().UTC()}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			m.Files = append(m.Files, ManifestFile{Path: rel, Link: link})
+		case d.Type().IsRegular():
+			size, sum, err := hashFile(p)
+			if err != nil {
+				return err
+			}
+			m.Files = append(m.Files, ManifestFile{Path: rel, Size: size, SHA256: sum})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Verify compares dir with the manifest. Files with the recorded size are
+// hashed again; others count as modified without reading them. Symlinks
+// must point to the recorded target.
+func (m *Manifest) Verify(ctx context.Context, dir string) (*VerifyReport, error) {
+	want := map[string]ManifestFile{}
+	for _, f := range m.Files {
+		want[f.Path] = f
+	}
+
+	report := &VerifyReport{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		isLink := d.Type()&fs.ModeSymlink != 0
+		if d.IsDir() || (!isLink && !d.Type().IsRegular()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		f, ok := want[rel]
+		if !ok {
+			report.Extra = append(report.Extra, rel)
+			return nil
+		}
+		delete(want, rel)
+
+		if isLink || f.Link != "" {
+			if link, err := os.Readlink(p); err != nil || link != f.Link {
+				report.Modified = append(report.Modified, rel)
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() != f.Size {
+			report.Modified = append(report.Modified, rel)
+			return nil
+		}
+		if _, sum, err := hashFile(p); err != nil {
+			return err
+		} else if sum != f.SHA256 {
+			report.Modified = append(report.Modified, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for rel := range want {
+		report.Missing = append(report.Missing, rel)
+	}
+	slices.Sort(report.Missing)
+	return report, nil
+}
+
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func manifestPath(folder string) (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "manifests", folder+".json"), nil
+}
+
+// LoadManifest reads the manifest recorded for a mod folder, returning
+// NoManifestError when there is none yet.
+func LoadManifest(folder string) (*Manifest, error) {
+	path, err := manifestPath(folder)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &NoManifestError{Folder: folder}
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func saveManifest(m *Manifest) error {
+	path, err := manifestPath(m.Folder)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// manifestCurrent reports whether the manifest of mod was recorded at its
+// current Workshop update time.
+func manifestCurrent(mod ModEntry) bool {
+	m, err := LoadManifest(mod.Folder)
+	return err == nil && m.UpdatedAt.Equal(mod.UpdatedAt)
+}
+
+// recordManifest hashes the folder of mod and saves its manifest.
+func recordManifest(ctx context.Context, root string, mod ModEntry) (*Manifest, error) {
+	m, err := BuildManifest(ctx, filepath.Join(root, mod.Folder))
+	if err != nil {
+		return nil, err
+	}
+	m.UpdatedAt = mod.UpdatedAt
+	return m, saveManifest(m)
+}
+
+// updateManifests records the manifests of mods that have none for their
+// current update time, or of all of them when full is set, hashing several
+// at a time. Manifests already recorded are kept, so a scan cancelled
+// while hashing picks up where it stopped. A mod that cannot be read is
+// skipped; Verify records it later.
+func updateManifests(ctx context.Context, root string, mods []ModEntry, full bool, report func(ScanProgress)) error {
+	var todo []int
+	for i, m := range mods {
+		if full || !manifestCurrent(m) {
+			todo = append(todo, i)
+		}
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+
+	done := make(chan int, len(todo))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 4)
+
+	for _, i := range todo {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			if _, err := recordManifest(ctx, root, mods[i]); err != nil {
+				if !errors.Is(err, context.Canceled) {
+					slog.Warn("cannot record manifest", "folder", mods[i].Folder, "err", err)
+				}
+				return
+			}
+			done <- i
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	n := 0
+	for i := range done {
+		n++
+		report(ScanProgress{Phase: ScanHashing, Folder: mods[i].Folder, Index: n, Total: len(todo)})
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateManifestsKeepsCurrentOnes(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	root := t.TempDir()
+	for _, folder := range []string{"1", "2"} {
+		if err := os.MkdirAll(filepath.Join(root, folder), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, folder, "script.rpy"), []byte(folder), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updated := time.Unix(1700000000, 0).UTC()
+	mods := []ModEntry{{Folder: "1", UpdatedAt: updated}, {Folder: "2", UpdatedAt: updated}}
+
+	// As if a scan was cancelled after recording the first mod.
+	if _, err := recordManifest(context.Background(), root, mods[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	var hashed []string
+	err := updateManifests(context.Background(), root, mods, false, func(p ScanProgress) {
+		hashed = append(hashed, p.Folder)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashed) != 1 || hashed[0] != "2" {
+		t.Errorf("hashed %v, want [2]", hashed)
+	}
+
+	mods[0].UpdatedAt = updated.Add(time.Hour)
+	if manifestCurrent(mods[0]) {
+		t.Error("manifest of an updated mod is current")
+	}
+}
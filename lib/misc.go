@@ -9,8 +9,8 @@ import (
 
 func sortModsByName(mods []ModEntry) {
 	sort.Slice(mods, func(i, j int) bool {
-		a := mods[i].Name
-		b := mods[j].Name
+		a := mods[i].DisplayName()
+		b := mods[j].DisplayName()
 
 		aFirst := []rune(a)[0]
 		bFirst := []rune(b)[0]
@@ -30,19 +30,50 @@ func PrintMods(db *ModsDB) {
 
 	maxCodeLen := 0
 	for _, m := range db.Mods {
-		if len(m.CodeName) > maxCodeLen {
-			maxCodeLen = len(m.CodeName)
+		if len(m.DisplayCodeName()) > maxCodeLen {
+			maxCodeLen = len(m.DisplayCodeName())
 		}
 	}
 	codeColWidth := maxCodeLen + 4
 
-	fmt.Printf("%-9s %-*s %s\n", "Enabled", codeColWidth, "CodeName", "Name")
+	fmt.Printf("%-9s %-*s %-10s %-10s %s\n", "Enabled", codeColWidth, "CodeName", "Size", "Updated", "Name")
 
 	for _, m := range db.Mods {
 		enabled := "❌"
 		if m.Enabled {
 			enabled = "✅"
 		}
-		fmt.Printf("%-8s %-*s %s\n", enabled, codeColWidth, m.CodeName, m.Name)
+
+		updated := "-"
+		if !m.UpdatedAt.IsZero() {
+			updated = m.UpdatedAt.Local().Format("2006-01-02")
+		}
+
+		name := m.DisplayName()
+		if m.NeedsUpdate {
+			name += " [" + T_("needs update") + "]"
+		}
+		if m.Unsubscribed {
+			name += " [" + T_("not subscribed") + "]"
+		}
+
+		fmt.Printf("%-8s %-*s %-10s %-10s %s\n", enabled, codeColWidth, m.DisplayCodeName(), FormatSize(m.Size), updated, name)
+	}
+}
+
+// FormatSize renders a byte count in binary units, or "-" when unknown.
+func FormatSize(n int64) string {
+	if n <= 0 {
+		return "-"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
 	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
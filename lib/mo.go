@@ -0,0 +1,382 @@
+package lib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	moMagic      = 0x950412de
+	moContextSep = "\x04"
+	moPluralSep  = "\x00"
+)
+
+// Catalog is a gettext message catalog read from a .mo file. A nil Catalog
+// translates nothing and returns the messages as given.
+type Catalog struct {
+	messages map[string][]string
+	plural   pluralExpr
+	nplurals int
+}
+
+func LoadCatalog(path string) (*Catalog, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseCatalog(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ParseCatalog reads the contents of a .mo file in either byte order.
+func ParseCatalog(b []byte) (*Catalog, error) {
+	if len(b) < 28 {
+		return nil, errors.New("mo: file too short")
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if binary.LittleEndian.Uint32(b) != moMagic {
+		order = binary.BigEndian
+		if order.Uint32(b) != moMagic {
+			return nil, errors.New("mo: bad magic number")
+		}
+	}
+
+	count := int(order.Uint32(b[8:]))
+	origTable := int(order.Uint32(b[12:]))
+	transTable := int(order.Uint32(b[16:]))
+	if count > (len(b)-28)/16 {
+		return nil, errors.New("mo: string count out of range")
+	}
+
+	str := func(table, i int) (string, error) {
+		at := table + i*8
+		if at < 0 || at+8 > len(b) {
+			return "", errors.New("mo: string table out of range")
+		}
+		length := int(order.Uint32(b[at:]))
+		offset := int(order.Uint32(b[at+4:]))
+		if offset < 0 || length < 0 || offset+length > len(b) {
+			return "", errors.New("mo: string out of range")
+		}
+		return string(b[offset : offset+length]), nil
+	}
+
+	c := &Catalog{messages: make(map[string][]string, count), nplurals: 2}
+	for i := 0; i < count; i++ {
+		orig, err := str(origTable, i)
+		if err != nil {
+			return nil, err
+		}
+		trans, err := str(transTable, i)
+		if err != nil {
+			return nil, err
+		}
+
+		// The key is the singular msgid, with its context if any.
+		key, _, _ := strings.Cut(orig, moPluralSep)
+		c.messages[key] = strings.Split(trans, moPluralSep)
+	}
+
+	if header, ok := c.messages[""]; ok {
+		if err := c.parseHeader(header[0]); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// parseHeader reads the Plural-Forms line of the catalog header.
+func (c *Catalog) parseHeader(header string) error {
+	for line := range strings.SplitSeq(header, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "Plural-Forms") {
+			continue
+		}
+
+		for field := range strings.SplitSeq(value, ";") {
+			k, v, _ := strings.Cut(field, "=")
+			switch strings.TrimSpace(k) {
+			case "nplurals":
+				n, err := strconv.Atoi(strings.TrimSpace(v))
+				if err != nil {
+					return fmt.Errorf("mo: bad nplurals: %w", err)
+				}
+				c.nplurals = n
+			case "plural":
+				expr, err := parsePluralExpr(v)
+				if err != nil {
+					return err
+				}
+				c.plural = expr
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Catalog) lookup(key string) []string {
+	if c == nil {
+		return nil
+	}
+	if t := c.messages[key]; len(t) > 0 && t[0] != "" {
+		return t
+	}
+	return nil
+}
+
+func (c *Catalog) pluralIndex(n int) int {
+	if c.plural == nil {
+		if n == 1 {
+			return 0
+		}
+		return 1
+	}
+	i := c.plural(n)
+	if i < 0 || i >= c.nplurals {
+		return 0
+	}
+	return i
+}
+
+func (c *Catalog) Gettext(msgid string) string {
+	if t := c.lookup(msgid); t != nil {
+		return t[0]
+	}
+	return msgid
+}
+
+func (c *Catalog) NGettext(msgid, plural string, n int) string {
+	if t := c.lookup(msgid); t != nil {
+		if i := c.pluralIndex(n); i < len(t) {
+			return t[i]
+		}
+	}
+	if n == 1 {
+		return msgid
+	}
+	return plural
+}
+
+func (c *Catalog) PGettext(context, msgid string) string {
+	if t := c.lookup(context + moContextSep + msgid); t != nil {
+		return t[0]
+	}
+	return msgid
+}
+
+func (c *Catalog) NPGettext(context, msgid, plural string, n int) string {
+	if t := c.lookup(context + moContextSep + msgid); t != nil {
+		if i := c.pluralIndex(n); i < len(t) {
+			return t[i]
+		}
+	}
+	if n == 1 {
+		return msgid
+	}
+	return plural
+}
+
+// pluralExpr is a compiled Plural-Forms expression.
+type pluralExpr func(n int) int
+
+// parsePluralExpr compiles the C expression of a Plural-Forms header, such
+// as "(n%10==1 && n%100!=11 ? 0 : n != 1)".
+func parsePluralExpr(src string) (pluralExpr, error) {
+	p := &pluralParser{src: strings.TrimSpace(src)}
+	expr, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		return nil, fmt.Errorf("mo: unexpected %q in plural expression", p.src[p.pos:])
+	}
+	return expr, nil
+}
+
+type pluralParser struct {
+	src string
+	pos int
+}
+
+func (p *pluralParser) skipSpace() {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\n\r", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes op if it comes next.
+func (p *pluralParser) accept(op string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], op) {
+		p.pos += len(op)
+		return true
+	}
+	return false
+}
+
+func (p *pluralParser) ternary() (pluralExpr, error) {
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return cond, nil
+	}
+	yes, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(":") {
+		return nil, errors.New("mo: expected ':' in plural expression")
+	}
+	no, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return func(n int) int {
+		if cond(n) != 0 {
+			return yes(n)
+		}
+		return no(n)
+	}, nil
+}
+
+// pluralOps lists binary operators from the lowest precedence up. Longer
+// operators come before their prefixes.
+var pluralOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *pluralParser) binary(level int) (pluralExpr, error) {
+	if level == len(pluralOps) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, candidate := range pluralOps[level] {
+			if p.accept(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = pluralBinary(op, left, right)
+	}
+}
+
+func pluralBinary(op string, a, b pluralExpr) pluralExpr {
+	boolInt := func(v bool) int {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return func(n int) int {
+		x := a(n)
+		switch op {
+		case "||":
+			return boolInt(x != 0 || b(n) != 0)
+		case "&&":
+			return boolInt(x != 0 && b(n) != 0)
+		}
+
+		y := b(n)
+		switch op {
+		case "==":
+			return boolInt(x == y)
+		case "!=":
+			return boolInt(x != y)
+		case "<=":
+			return boolInt(x <= y)
+		case ">=":
+			return boolInt(x >= y)
+		case "<":
+			return boolInt(x < y)
+		case ">":
+			return boolInt(x > y)
+		case "+":
+			return x + y
+		case "-":
+			return x - y
+		case "*":
+			return x * y
+		case "/":
+			if y == 0 {
+				return 0
+			}
+			return x / y
+		case "%":
+			if y == 0 {
+				return 0
+			}
+			return x % y
+		}
+		return 0
+	}
+}
+
+func (p *pluralParser) unary() (pluralExpr, error) {
+	if p.accept("!") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(n int) int {
+			if e(n) == 0 {
+				return 1
+			}
+			return 0
+		}, nil
+	}
+
+	if p.accept("(") {
+		e, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, errors.New("mo: expected ')' in plural expression")
+		}
+		return e, nil
+	}
+
+	if p.accept("n") {
+		return func(n int) int { return n }, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return nil, fmt.Errorf("mo: unexpected %q in plural expression", p.src[p.pos:])
+	}
+	v, _ := strconv.Atoi(p.src[start:p.pos])
+	return func(int) int { return v }, nil
+}
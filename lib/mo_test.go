@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"encoding/binary"
+	"sort"
+	"testing"
+)
+
+const ruPluralForms = "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);"
+
+// buildCatalog writes a .mo file holding messages, keyed by the msgid as
+// stored (with context and plural parts), in the given byte order.
+func buildCatalog(order binary.ByteOrder, messages map[string]string) []byte {
+	keys := make([]string, 0, len(messages))
+	for k := range messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	n := len(keys)
+	origTable, transTable := 28, 28+8*n
+	data := 28 + 16*n
+	b := make([]byte, data)
+	put := func(at int, v int) { order.PutUint32(b[at:], uint32(v)) }
+	put(0, moMagic)
+	put(8, n)
+	put(12, origTable)
+	put(16, transTable)
+
+	for i, k := range keys {
+		for _, s := range []struct {
+			table int
+			text  string
+		}{{origTable, k}, {transTable, messages[k]}} {
+			put(s.table+8*i, len(s.text))
+			put(s.table+8*i+4, len(b))
+			b = append(b, s.text...)
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+var testMessages = map[string]string{
+	"":                                      "Content-Type: text/plain; charset=UTF-8\nPlural-Forms: " + ruPluralForms + "\n",
+	"Close":                                 "Закрыть",
+	"{count} mod\x00{count} mods":           "{count} мод\x00{count} мода\x00{count} модов",
+	"menu\x04Open":                          "Открыть",
+	"menu\x04{count} file\x00{count} files": "{count} файл\x00{count} файла\x00{count} файлов",
+}
+
+func TestParseCatalog(t *testing.T) {
+	for name, order := range map[string]binary.ByteOrder{"little-endian": binary.LittleEndian, "big-endian": binary.BigEndian} {
+		c, err := ParseCatalog(buildCatalog(order, testMessages))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, tt := range []struct{ got, want string }{
+			{c.Gettext("Close"), "Закрыть"},
+			{c.Gettext("Open"), "Open"},
+			{c.PGettext("menu", "Open"), "Открыть"},
+			{c.PGettext("other", "Open"), "Open"},
+			{c.NGettext("{count} mod", "{count} mods", 2), "{count} мода"},
+			{c.NGettext("{count} mod", "{count} mods", 5), "{count} модов"},
+			{c.NPGettext("menu", "{count} file", "{count} files", 21), "{count} файл"},
+			{c.NPGettext("other", "{count} file", "{count} files", 3), "{count} files"},
+			{c.NGettext("{count} dir", "{count} dirs", 1), "{count} dir"},
+		} {
+			if tt.got != tt.want {
+				t.Errorf("%s: got %q, want %q", name, tt.got, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseCatalogCorrupted(t *testing.T) {
+	valid := buildCatalog(binary.LittleEndian, testMessages)
+	for i := range valid {
+		if _, err := ParseCatalog(valid[:i]); err == nil && i < 28 {
+			t.Errorf("a %d-byte file parsed", i)
+		}
+	}
+
+	for name, corrupt := range map[string]func(b []byte){
+		"bad magic":          func(b []byte) { b[0] ^= 0xff },
+		"huge count":         func(b []byte) { binary.LittleEndian.PutUint32(b[8:], 0xffffffff) },
+		"table out of range": func(b []byte) { binary.LittleEndian.PutUint32(b[12:], 0xfffffff0) },
+		"string out of range": func(b []byte) {
+			binary.LittleEndian.PutUint32(b[28:], 10)
+			binary.LittleEndian.PutUint32(b[28+4:], uint32(len(b)))
+		},
+	} {
+		b := buildCatalog(binary.LittleEndian, testMessages)
+		corrupt(b)
+		if _, err := ParseCatalog(b); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestPluralExpr(t *testing.T) {
+	c := &Catalog{nplurals: 3}
+	if err := c.parseHeader("Plural-Forms: " + ruPluralForms); err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range map[int]int{0: 2, 1: 0, 2: 1, 4: 1, 5: 2, 11: 2, 12: 2, 21: 0, 22: 1, 111: 2, 112: 2, 121: 0} {
+		if got := c.pluralIndex(n); got != want {
+			t.Errorf("n = %d: form %d, want %d", n, got, want)
+		}
+	}
+
+	for _, src := range []string{"n ==", "(n != 1", "n ? 1", "x", "n 1"} {
+		if _, err := parsePluralExpr(src); err == nil {
+			t.Errorf("%q parsed", src)
+		}
+	}
+	for src, want := range map[string]int{"n != 1": 1, "!(n > 1)": 0, "n * 2 + 1 - n / 2 % 3": 13, "n >= 5 || n <= 1": 1} {
+		e, err := parsePluralExpr(src)
+		if err != nil {
+			t.Errorf("%q: %v", src, err)
+			continue
+		}
+		if got := e(6); got != want {
+			t.Errorf("%q at 6 = %d, want %d", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var coverExts = []string{".png", ".jpg", ".jpeg", ".gif", ".webp"}
+
+// DisplayName is the name to show: the user's override, or the extracted
+// name.
+func (m ModEntry) DisplayName() string {
+	if m.Overrides.Name != "" {
+		return m.Overrides.Name
+	}
+	return m.Name
+}
+
+// DisplayCodeName is the codename alias, or the extracted codename.
+func (m ModEntry) DisplayCodeName() string {
+	if m.Overrides.CodeName != "" {
+		return m.Overrides.CodeName
+	}
+	return m.CodeName
+}
+
+// Matches reports whether id names the mod by folder, codename or alias.
+func (m ModEntry) Matches(id string) bool {
+	return id == m.Folder || id == m.CodeName ||
+		(m.Overrides.CodeName != "" && id == m.Overrides.CodeName)
+}
+
+func findMod(db *ModsDB, id string) (*ModEntry, error) {
+	for i := range db.Mods {
+		if db.Mods[i].Matches(id) {
+			return &db.Mods[i], nil
+		}
+	}
+	return nil, &ModNotFoundError{ID: id}
+}
+
+// renameMod sets the display name of a mod. An empty name, or the extracted
+// one, removes the override.
+func renameMod(db *ModsDB, id, name string) error {
+	m, err := findMod(db, id)
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if name == m.Name {
+		name = ""
+	}
+	m.Overrides.Name = name
+	return nil
+}
+
+// setModAlias sets another codename the mod answers to in commands. It must
+// not name any other mod, nor be ALL, which commands take for every mod.
+func setModAlias(db *ModsDB, id, alias string) error {
+	m, err := findMod(db, id)
+	if err != nil {
+		return err
+	}
+	alias = strings.TrimSpace(alias)
+	if alias == m.CodeName {
+		alias = ""
+	}
+	if alias == "ALL" {
+		return fmt.Errorf(T_("%s cannot be used as an alias"), alias)
+	}
+	if alias != "" {
+		if other, err := findMod(db, alias); err == nil && other.Folder != m.Folder {
+			return fmt.Errorf(T_("%s already names mod %s"), alias, other.Folder)
+		}
+	}
+	m.Overrides.CodeName = alias
+	return nil
+}
+
+// setModCover copies image into the data directory and uses it as the
+// cover of the mod, so the original can be moved or deleted. An empty
+// image goes back to the Workshop preview.
+func setModCover(db *ModsDB, id, image string) error {
+	m, err := findMod(db, id)
+	if err != nil {
+		return err
+	}
+
+	old := m.Overrides.Cover
+	if image == "" {
+		m.Overrides.Cover = ""
+		if old != "" {
+			os.Remove(old)
+		}
+		return nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(image))
+	if !slices.Contains(coverExts, ext) {
+		return fmt.Errorf(T_("not a supported image: %s"), image)
+	}
+
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	dir = filepath.Join(dir, "covers")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	dst := filepath.Join(dir, m.Folder+ext)
+	if err := copyFile(image, dst); err != nil {
+		return err
+	}
+
+	if old != "" && old != dst {
+		os.Remove(old)
+	}
+	m.Overrides.Cover = dst
+	return nil
+}
+
+// copyFile copies src to dst through a temporary file, so that dst is
+// never left half-written, even when src is dst itself.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".partial"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetModCoverToItself(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	image := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(image, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &ModsDB{Mods: []ModEntry{{Folder: "1"}}}
+	if err := setModCover(db, "1", image); err != nil {
+		t.Fatal(err)
+	}
+	stored := db.Mods[0].Overrides.Cover
+	if err := setModCover(db, "1", stored); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(stored); err != nil || string(b) != "png" {
+		t.Errorf("cover = %q, %v", b, err)
+	}
+}
+
+func TestSetModAliasRejectsAll(t *testing.T) {
+	db := &ModsDB{Mods: []ModEntry{{Folder: "1", CodeName: "one"}}}
+	if err := setModAlias(db, "1", "ALL"); err == nil {
+		t.Error("ALL accepted as an alias")
+	}
+}
@@ -2,8 +2,12 @@ package lib
 
 import (
 	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,17 +15,56 @@ import (
 	"time"
 )
 
-func ScanAndUpdate(cfg *Config, db *ModsDB) {
+// ScanPhase names the step a scan is in.
+type ScanPhase string
+
+const (
+	// ScanReading lists the Workshop folder.
+	ScanReading ScanPhase = "reading"
+	// ScanExtracting checks the fingerprint of every folder and reads the
+	// names of new and changed ones from their scripts, asking Steam when
+	// they have none.
+	ScanExtracting ScanPhase = "extracting"
+	// ScanWorkshop reads sizes and update state from Steam's manifest.
+	ScanWorkshop ScanPhase = "workshop"
+	// ScanHashing records file manifests of new and updated mods.
+	ScanHashing ScanPhase = "hashing"
+)
+
+// ScanProgress reports a step of ScanAndUpdate. While extracting and
+// hashing, Folder is the folder just done and Index counts the done folders
+// out of Total.
+type ScanProgress struct {
+	Phase  ScanPhase
+	Folder string
+	Index  int
+	Total  int
+}
+
+// ScanAndUpdate brings db up to date with the Workshop folder: new folders
+// are added with names from their scripts, folders whose scripts changed
+// are read again and missing ones are dropped. File manifests are recorded
+// for new and updated mods. full reads every folder and records every
+// manifest again. progress, if not nil, is called from the calling goroutine. If ctx
+// is done before the scan finishes, db is left as it was and ctx.Err() is
+// returned.
+func ScanAndUpdate(ctx context.Context, cfg *Config, db *ModsDB, full bool, progress func(ScanProgress)) error {
+	report := func(p ScanProgress) {
+		if progress != nil {
+			progress(p)
+		}
+	}
+
 	root := cfg.Root
 	if root == "" {
-		log.Println("scan skipped: workshop_root is empty")
-		return
+		slog.Warn("scan skipped: workshop_root is empty")
+		return nil
 	}
 
+	report(ScanProgress{Phase: ScanReading})
 	entries, err := os.ReadDir(root)
 	if err != nil {
-		log.Println("scan error:", err)
-		return
+		return err
 	}
 
 	type result struct {
@@ -29,52 +72,39 @@ func ScanAndUpdate(cfg *Config, db *ModsDB) {
 		entry  ModEntry
 	}
 
-	results := make(chan result, len(entries))
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, 10)
-
-	existingFolders := map[string]bool{}
 	existingMods := map[string]ModEntry{}
-
 	for _, m := range db.Mods {
 		existingMods[m.Folder] = m
 	}
 
+	var folders []string
 	for _, e := range entries {
-		if !e.IsDir() {
-			continue
-		}
-
-		folder := e.Name()
-		if strings.HasPrefix(folder, ".") {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
 			continue
 		}
+		folders = append(folders, e.Name())
+	}
 
-		existingFolders[folder] = true
-
-		if _, ok := existingMods[folder]; ok {
-			continue
-		}
+	results := make(chan result, len(folders))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 10)
 
+	for _, folder := range folders {
 		wg.Add(1)
 		go func(folder string) {
 			defer wg.Done()
-			sem <- struct{}{}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
 			defer func() { <-sem }()
-
-			fullPath := filepath.Join(root, folder)
-			codename, name := extractFromFolder(fullPath)
-
-			results <- result{
-				folder: folder,
-				entry: ModEntry{
-					Name:         name,
-					CodeName:     codename,
-					Folder:       folder,
-					Enabled:      true,
-					DiscoveredAt: time.Now().UTC(),
-				},
+			if ctx.Err() != nil {
+				return
 			}
+
+			old, known := existingMods[folder]
+			results <- result{folder: folder, entry: scanFolder(ctx, cfg, folder, old, known, full)}
 		}(folder)
 	}
 
@@ -86,45 +116,110 @@ func ScanAndUpdate(cfg *Config, db *ModsDB) {
 	found := map[string]ModEntry{}
 	for r := range results {
 		found[r.folder] = r.entry
+		report(ScanProgress{Phase: ScanExtracting, Folder: r.folder, Index: len(found), Total: len(folders)})
+	}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	newList := make([]ModEntry, 0, len(existingFolders))
-
+	newList := make([]ModEntry, 0, len(folders))
 	for _, m := range db.Mods {
-		if !existingFolders[m.Folder] {
-			log.Println("removing missing mod:", m.Folder)
-			continue
-		}
-
 		if fresh, ok := found[m.Folder]; ok {
-			fresh.Enabled = m.Enabled
-			fresh.DiscoveredAt = m.DiscoveredAt
-			if m.Name != "" {
-				fresh.Name = m.Name
-			}
 			newList = append(newList, fresh)
 		} else {
-			newList = append(newList, m)
+			slog.Info("removing missing mod", "folder", m.Folder)
 		}
 	}
-
-	for folder, m := range found {
+	for _, folder := range folders {
 		if _, ok := existingMods[folder]; !ok {
-			newList = append(newList, m)
+			newList = append(newList, found[folder])
 		}
 	}
 
+	report(ScanProgress{Phase: ScanWorkshop})
+	mergeWorkshopState(root, newList)
+
+	// Manifests are recorded for mods that are new or that Steam updated
+	// since the last scan; Verify records those of older mods.
+	var changed []ModEntry
+	for _, m := range newList {
+		old, known := existingMods[m.Folder]
+		if full || !known || !old.UpdatedAt.Equal(m.UpdatedAt) {
+			changed = append(changed, m)
+		}
+	}
+	if err := updateManifests(ctx, root, changed, full, report); err != nil {
+		return err
+	}
 	db.Mods = newList
+	return nil
 }
 
-func extractFromFolder(folder string) (codename, name string) {
-	log.Println("Extracting from folder:", folder)
-	codename, name = extractFromScripts(folder)
+// scanFolder returns the entry for one Workshop folder. A known mod is only
+// read again when its scripts changed or when full is set, so a mod that
+// neither its scripts nor Steam could name keeps its folder name until
+// then instead of being looked up on every scan. Mods recorded before
+// fingerprints existed just get one. Overrides are kept as they are.
+func scanFolder(ctx context.Context, cfg *Config, folder string, old ModEntry, known, full bool) ModEntry {
+	fullPath := filepath.Join(cfg.Root, folder)
+	fingerprint := modFingerprint(fullPath)
+
+	if known && !full && (old.Fingerprint == "" || old.Fingerprint == fingerprint) {
+		old.Fingerprint = fingerprint
+		return old
+	}
 
-	if codename == "" || name == "" {
+	codename, name := extractFromFolder(ctx, cfg, fullPath)
+	if !known {
+		return ModEntry{
+			Name:         name,
+			CodeName:     codename,
+			Folder:       folder,
+			Enabled:      true,
+			DiscoveredAt: time.Now().UTC(),
+			Fingerprint:  fingerprint,
+		}
+	}
+
+	slog.Debug("reading changed mod again", "folder", folder)
+	// The folder name is only a fallback; a name found earlier is better.
+	if name != folder || old.Name == "" {
+		old.Name = name
+	}
+	if codename != folder || old.CodeName == "" {
+		old.CodeName = codename
+	}
+	old.Fingerprint = fingerprint
+	return old
+}
+
+// modFingerprint sums the paths, sizes and modification times of the
+// scripts of a mod, where its names come from, without reading them.
+func modFingerprint(dir string) string {
+	h := sha256.New()
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".rpy") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", rel, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func extractFromFolder(ctx context.Context, cfg *Config, folder string) (codename, name string) {
+	slog.Debug("extracting from folder", "folder", folder)
+	codename, name = extractFromScripts(ctx, folder)
+
+	if (codename == "" || name == "") && ctx.Err() == nil {
 		id := filepath.Base(folder)
-		if title, err := FetchSteamTitle(id); err == nil && title != "" {
-			log.Println("Steam API success for", id)
+		if title, err := FetchSteamTitle(ctx, cfg, id); err == nil && title != "" {
+			slog.Debug("Steam API title found", "id", id)
 			if codename == "" {
 				codename = id
 			}
@@ -132,7 +227,7 @@ func extractFromFolder(folder string) (codename, name string) {
 				name = title
 			}
 		} else {
-			log.Println("Steam API failed for", id, "using folder name")
+			slog.Debug("Steam API title not found, using folder name", "id", id)
 		}
 	}
 
@@ -155,10 +250,8 @@ func parseRpyFile(path string) (codename, pretty string) {
 	s := bufio.NewScanner(f)
 	for s.Scan() {
 		line := s.Text()
-		m := modsLineRe.FindStringSubmatch(line)
-		if len(m) == 3 {
-			k := m[1]
-			val := strings.TrimSpace(m[2])
+		if k, val, ok := CurrentGame().extract(line); ok {
+			val = strings.TrimSpace(val)
 			clean := braceRe.ReplaceAllString(val, "")
 			clean = strings.ReplaceAll(clean, `\"`, `"`)
 			clean = strings.Trim(clean, `"`)
@@ -169,9 +262,12 @@ func parseRpyFile(path string) (codename, pretty string) {
 	return "", ""
 }
 
-func extractFromScripts(folder string) (codename, name string) {
+func extractFromScripts(ctx context.Context, folder string) (codename, name string) {
 	codename, name = "", ""
 	filepath.WalkDir(folder, func(p string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return fs.SkipAll
+		}
 		if err != nil || d.IsDir() {
 			return nil
 		}
@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScanFolderRemembersFailedLookup(t *testing.T) {
+	var lookups atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := &Config{Root: t.TempDir(), SteamAPI: srv.URL}
+	if err := os.Mkdir(filepath.Join(cfg.Root, "111"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mod := scanFolder(ctx, cfg, "111", ModEntry{}, false, false)
+	if mod.Name != "111" || lookups.Load() != 1 {
+		t.Fatalf("name = %q after %d lookups", mod.Name, lookups.Load())
+	}
+	mod = scanFolder(ctx, cfg, "111", mod, true, false)
+	if lookups.Load() != 1 {
+		t.Error("an unchanged mod was looked up again")
+	}
+	scanFolder(ctx, cfg, "111", mod, true, true)
+	if lookups.Load() != 2 {
+		t.Error("a full scan did not look the mod up again")
+	}
+
+	writeFiles(t, filepath.Join(cfg.Root, "111"), map[string]string{"mod.rpy": "init:\n"}, time.Now())
+	scanFolder(ctx, cfg, "111", mod, true, false)
+	if lookups.Load() != 3 {
+		t.Error("a changed mod was not read again")
+	}
+}
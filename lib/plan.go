@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// PlannedMove is one folder moveDisabledMods is going to move aside.
+type PlannedMove struct {
+	Src  string
+	Dst  string
+	Copy bool // on another filesystem: copied and deleted, not renamed
+	Size int64
+}
+
+// MovePlan describes what a launch will do with the Workshop folders.
+type MovePlan struct {
+	Moves     []PlannedMove
+	Target    string
+	CopyBytes int64
+	FreeBytes int64 // -1 when it could not be determined
+}
+
+// PlanLaunch works out the moves for the disabled mods without touching
+// anything on disk.
+func PlanLaunch(cfg *Config, db *ModsDB) *MovePlan {
+	plan := &MovePlan{Target: getDisabledDir(cfg), FreeBytes: freeSpace(getDisabledDir(cfg))}
+
+	for _, m := range db.Mods {
+		if m.Enabled {
+			continue
+		}
+
+		src := filepath.Join(cfg.Root, m.Folder)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+
+		move := PlannedMove{
+			Src:  src,
+			Dst:  filepath.Join(plan.Target, m.Folder),
+			Copy: !sameDevice(src, plan.Target),
+		}
+		if move.Copy {
+			move.Size = dirSize(src)
+			plan.CopyBytes += move.Size
+		}
+		plan.Moves = append(plan.Moves, move)
+	}
+	return plan
+}
+
+// NeedsCopy reports whether any folder has to be copied across devices.
+func (p *MovePlan) NeedsCopy() bool {
+	for _, m := range p.Moves {
+		if m.Copy {
+			return true
+		}
+	}
+	return false
+}
+
+// FitsOnDisk reports whether the copies fit into the free space of the
+// target. An unknown amount of free space is treated as enough.
+func (p *MovePlan) FitsOnDisk() bool {
+	return p.FreeBytes < 0 || p.CopyBytes <= p.FreeBytes
+}
+
+// freeSpace returns the bytes available to the user on the filesystem of
+// path, looking at the nearest existing parent.
+func freeSpace(path string) int64 {
+	for {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err == nil {
+			return int64(st.Bavail) * int64(st.Bsize)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return -1
+		}
+		path = parent
+	}
+}
+
+func (p *MovePlan) String() string {
+	if len(p.Moves) == 0 {
+		return T_("No folders to move.") + "\n"
+	}
+
+	s := ""
+	for _, m := range p.Moves {
+		kind := T_("rename")
+		if m.Copy {
+			kind = T_("copy") + " " + FormatSize(m.Size)
+		}
+		s += fmt.Sprintf("%s\n  -> %s  (%s)\n", m.Src, m.Dst, kind)
+	}
+
+	s += "\n" + Format(N_("{count} folder to move", "{count} folders to move", len(p.Moves)),
+		Args{"count": len(p.Moves)}) + "\n"
+	if p.NeedsCopy() {
+		s += fmt.Sprintf(T_("Bytes to copy: %s")+"\n", FormatSize(p.CopyBytes))
+	} else {
+		s += T_("Nothing to copy, all folders are renamed.") + "\n"
+	}
+	if p.FreeBytes >= 0 {
+		s += fmt.Sprintf(T_("Free space in %s: %s")+"\n", p.Target, FormatSize(p.FreeBytes))
+	}
+	if !p.FitsOnDisk() {
+		s += T_("warning: not enough free space for the copies") + "\n"
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package lib
+
+import "errors"
+
+// Profile is a named set of enabled mods, stored by folder.
+type Profile struct {
+	Name string   `yaml:"name"`
+	Mods []string `yaml:"mods"`
+}
+
+func FindProfile(db *ModsDB, name string) (*Profile, error) {
+	for i := range db.Profiles {
+		if db.Profiles[i].Name == name {
+			return &db.Profiles[i], nil
+		}
+	}
+	return nil, &ProfileNotFoundError{Name: name}
+}
+
+// SaveProfile stores the given folders under name, replacing an existing
+// profile with the same name.
+func SaveProfile(db *ModsDB, name string, folders []string) error {
+	if name == "" {
+		return errors.New(T_("profile name is empty"))
+	}
+	if p, err := FindProfile(db, name); err == nil {
+		p.Mods = folders
+		return nil
+	}
+	db.Profiles = append(db.Profiles, Profile{Name: name, Mods: folders})
+	return nil
+}
+
+func DeleteProfile(db *ModsDB, name string) error {
+	for i := range db.Profiles {
+		if db.Profiles[i].Name == name {
+			db.Profiles = append(db.Profiles[:i], db.Profiles[i+1:]...)
+			if db.ActiveProfile == name {
+				db.ActiveProfile = ""
+			}
+			return nil
+		}
+	}
+	return &ProfileNotFoundError{Name: name}
+}
+
+// ApplyProfile enables exactly the mods of the profile and marks it active.
+func ApplyProfile(db *ModsDB, name string) error {
+	p, err := FindProfile(db, name)
+	if err != nil {
+		return err
+	}
+	setEnabledSet(db, p.Mods)
+	db.ActiveProfile = name
+	return nil
+}
+
+func EnabledFolders(db *ModsDB) []string {
+	folders := []string{}
+	for _, m := range db.Mods {
+		if m.Enabled {
+			folders = append(folders, m.Folder)
+		}
+	}
+	return folders
+}
+
+// WithEnabledSet returns a copy of the database with exactly the given
+// folders enabled, for launches that must not change the saved selection.
+func WithEnabledSet(db *ModsDB, folders []string) *ModsDB {
+	cp := *db
+	cp.Mods = append([]ModEntry(nil), db.Mods...)
+	setEnabledSet(&cp, folders)
+	return &cp
+}
+
+// setEnabledSet enables the listed folders and disables everything else.
+func setEnabledSet(db *ModsDB, folders []string) {
+	want := map[string]bool{}
+	for _, f := range folders {
+		want[f] = true
+	}
+	for i := range db.Mods {
+		db.Mods[i].Enabled = want[db.Mods[i].Folder]
+	}
+}
@@ -0,0 +1,376 @@
+package lib
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SaveIsolationOff     = "off"
+	SaveIsolationProfile = "profile"
+	SaveIsolationModSet  = "modset"
+)
+
+// liveSlot holds the player's own saves while a slot is swapped in.
+const liveSlot = "_live"
+
+// saveSwap is the journal of a swap in progress. It is written before any
+// directory is touched, so an interrupted swap can be undone on next run.
+type saveSwap struct {
+	Slot      string    `yaml:"slot"`
+	Dirs      []string  `yaml:"dirs"`
+	StartedAt time.Time `yaml:"started_at"`
+}
+
+type SaveBackup struct {
+	Name string
+	Time time.Time
+	Size int64
+}
+
+func savesStoreDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "saves"), nil
+}
+
+func saveSwapPath() (string, error) {
+	dir, err := savesStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "swap.yaml"), nil
+}
+
+// SaveDirs returns the directories Ren'Py keeps saves and persistent data
+// in: save_dirs from the config, or ~/.renpy/<game> and the game's saves
+// directory.
+func SaveDirs(cfg *Config) []string {
+	if len(cfg.SaveDirs) > 0 {
+		return cfg.SaveDirs
+	}
+
+	var dirs []string
+	home, _ := os.UserHomeDir()
+	pattern := strings.ToLower(CurrentGame().RenpyDir)
+	entries, _ := os.ReadDir(filepath.Join(home, ".renpy"))
+	for _, e := range entries {
+		if pattern != "" && e.IsDir() && strings.Contains(strings.ToLower(e.Name()), pattern) {
+			dirs = append(dirs, filepath.Join(home, ".renpy", e.Name()))
+		}
+	}
+	if cfg.GameDir != "" {
+		dirs = append(dirs, filepath.Join(cfg.GameDir, "game", "saves"))
+	}
+	return dirs
+}
+
+// saveSlot names the snapshot that belongs to the current mod setup, or
+// returns "" when saves are shared by all setups.
+func saveSlot(cfg *Config, db *ModsDB) string {
+	switch cfg.SaveIsolation {
+	case SaveIsolationProfile:
+		if db.ActiveProfile == "" {
+			return ""
+		}
+		return "profile-" + sanitizeName(db.ActiveProfile)
+	case SaveIsolationModSet:
+		folders := EnabledFolders(db)
+		sort.Strings(folders)
+		sum := sha1.Sum([]byte(strings.Join(folders, ",")))
+		return "mods-" + hex.EncodeToString(sum[:])[:12]
+	}
+	return ""
+}
+
+func sanitizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// backupName sanitizes the name of a backup into a single path element,
+// refusing names that would resolve to the backups directory or above it.
+func backupName(name string) (string, error) {
+	clean := sanitizeName(name)
+	if clean == "" || clean == "." || clean == ".." {
+		return "", fmt.Errorf(T_("invalid backup name: %q"), name)
+	}
+	return clean, nil
+}
+
+// dirKey gives each save directory a stable name inside a snapshot.
+func dirKey(dir string) string {
+	sum := sha1.Sum([]byte(dir))
+	return filepath.Base(dir) + "-" + hex.EncodeToString(sum[:])[:8]
+}
+
+// swapInSaves moves the live saves aside and puts the snapshot of the
+// current mod setup in their place. It returns nil when there is nothing
+// to swap.
+func swapInSaves(cfg *Config, db *ModsDB) (*saveSwap, error) {
+	slot := saveSlot(cfg, db)
+	if slot == "" {
+		return nil, nil
+	}
+
+	store, err := savesStoreDir()
+	if err != nil {
+		return nil, err
+	}
+
+	swap := &saveSwap{Slot: slot, Dirs: SaveDirs(cfg), StartedAt: time.Now().UTC()}
+	if err := writeSaveSwap(swap); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range swap.Dirs {
+		live := filepath.Join(store, "slots", liveSlot, dirKey(dir))
+		saved := filepath.Join(store, "slots", slot, dirKey(dir))
+
+		if err := os.MkdirAll(filepath.Dir(live), 0755); err != nil {
+			return swap, err
+		}
+		// The live copy always exists after this step, even if the game
+		// has not created the directory yet; that is how recovery knows
+		// the swap went past it.
+		if _, err := os.Stat(dir); err == nil {
+			if err := moveDir(dir, live); err != nil {
+				return swap, err
+			}
+		} else if err := os.MkdirAll(live, 0755); err != nil {
+			return swap, err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return swap, err
+		}
+		if _, err := os.Stat(saved); err == nil {
+			if err := moveDir(saved, dir); err != nil {
+				return swap, err
+			}
+		} else if err := os.MkdirAll(dir, 0755); err != nil {
+			return swap, err
+		}
+	}
+
+	return swap, nil
+}
+
+// swapOutSaves stores the saves made during the session in their slot and
+// brings back the live saves. Every step checks what is on disk, so it is
+// safe to run again after a crash at any point.
+func swapOutSaves(swap *saveSwap) error {
+	if swap == nil {
+		return nil
+	}
+
+	store, err := savesStoreDir()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range swap.Dirs {
+		live := filepath.Join(store, "slots", liveSlot, dirKey(dir))
+		saved := filepath.Join(store, "slots", swap.Slot, dirKey(dir))
+
+		if _, err := os.Stat(live); os.IsNotExist(err) {
+			continue
+		}
+
+		if _, err := os.Stat(dir); err == nil {
+			if _, err := os.Stat(saved); err == nil {
+				saved += ".recovered-" + time.Now().Format("20060102-150405")
+			}
+			if err := os.MkdirAll(filepath.Dir(saved), 0755); err != nil {
+				return err
+			}
+			if err := moveDir(dir, saved); err != nil {
+				return err
+			}
+		}
+
+		if err := moveDir(live, dir); err != nil {
+			return err
+		}
+	}
+
+	path, err := saveSwapPath()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func writeSaveSwap(swap *saveSwap) error {
+	path, err := saveSwapPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, _ := yaml.Marshal(swap)
+	return os.WriteFile(path, b, 0644)
+}
+
+// RecoverSaves finishes a save swap left behind by a crash or a killed
+// session, putting the player's own saves back in place.
+func RecoverSaves() (bool, error) {
+	path, err := saveSwapPath()
+	if err != nil {
+		return false, err
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var swap saveSwap
+	if err := yaml.Unmarshal(b, &swap); err != nil {
+		return false, err
+	}
+	return true, swapOutSaves(&swap)
+}
+
+// BackupSaves copies the current saves into a named backup. An empty name
+// uses the current time.
+func BackupSaves(cfg *Config, name string) (string, error) {
+	if name == "" {
+		name = time.Now().Format("2006-01-02_15-04-05")
+	}
+	name, err := backupName(name)
+	if err != nil {
+		return "", err
+	}
+
+	store, err := savesStoreDir()
+	if err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(store, "backups", name)
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf(T_("backup already exists: %s"), name)
+	}
+
+	copied := 0
+	for _, dir := range SaveDirs(cfg) {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if _, err := newCopier(context.Background(), 0, nil).copyTree(dir, filepath.Join(dst, dirKey(dir))); err != nil {
+			return "", err
+		}
+		copied++
+	}
+	if copied == 0 {
+		return "", errors.New(T_("no save directories found"))
+	}
+	return name, nil
+}
+
+// RestoreSaves replaces the current saves with a backup. The saves being
+// replaced are backed up first.
+func RestoreSaves(cfg *Config, name string) error {
+	store, err := savesStoreDir()
+	if err != nil {
+		return err
+	}
+
+	clean, err := backupName(name)
+	if err != nil {
+		return err
+	}
+	src := filepath.Join(store, "backups", clean)
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf(T_("backup not found: %s"), name)
+	}
+
+	if _, err := BackupSaves(cfg, "before-restore-"+time.Now().Format("2006-01-02_15-04-05")); err != nil {
+		return err
+	}
+
+	for _, dir := range SaveDirs(cfg) {
+		backup := filepath.Join(src, dirKey(dir))
+		if _, err := os.Stat(backup); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if _, err := newCopier(context.Background(), 0, nil).copyTree(backup, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ListSaveBackups() ([]SaveBackup, error) {
+	store, err := savesStoreDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(store, "backups"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []SaveBackup
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, SaveBackup{
+			Name: e.Name(),
+			Time: info.ModTime(),
+			Size: dirSize(filepath.Join(store, "backups", e.Name())),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
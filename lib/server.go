@@ -0,0 +1,449 @@
+package lib
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed web/index.html
+var webPage []byte
+
+// Launch states reported by the server.
+const (
+	LaunchStateIdle      = "idle"
+	LaunchStateLaunching = "launching"
+	LaunchStateRunning   = "running"
+	LaunchStateRestoring = "restoring"
+)
+
+// eventNames are the SSE event names of the events the server passes on;
+// scan progress is left out.
+var eventNames = map[EventKind]string{
+	EventInfo:        "info",
+	EventLaunching:   "launching",
+	EventGameRunning: "running",
+	EventGameExited:  "exited",
+	EventRestored:    "restored",
+	EventCopying:     "copying",
+	EventConflict:    "conflict",
+	EventCrashed:     "crashed",
+}
+
+// LaunchStatus is the state of the server's launches, as returned by
+// /api/status. Error and Crash describe the last launch.
+type LaunchStatus struct {
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Crash   string `json:"crash,omitempty"`
+}
+
+type apiMod struct {
+	Folder      string    `json:"folder"`
+	Name        string    `json:"name"`
+	CodeName    string    `json:"codename"`
+	Enabled     bool      `json:"enabled"`
+	Size        int64     `json:"size,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+	NeedsUpdate bool      `json:"needs_update,omitempty"`
+	Cover       string    `json:"cover"`
+}
+
+type apiProfile struct {
+	Name   string   `json:"name"`
+	Mods   []string `json:"mods"`
+	Active bool     `json:"active"`
+}
+
+type apiEvent struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Done    int64  `json:"done,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+// Server is an HTTP API over a Manager, for managing mods from a phone or
+// another machine. Requests under /api need the token, as a bearer token
+// or in the token query parameter, which is all EventSource and img can
+// send. Launch events are streamed as server-sent events from /api/events.
+type Server struct {
+	Manager *Manager
+	Token   string
+	// CoverCache names the cover cache to share, see GetOrDownloadCover.
+	CoverCache string
+	// Web serves a small control page at /.
+	Web bool
+	// OnChange, if set, is called after the database was changed.
+	OnChange func()
+
+	ctx  context.Context
+	mux  *http.ServeMux
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	subs map[chan apiEvent]struct{}
+
+	status LaunchStatus
+}
+
+// NewServer returns a server for m, which it takes the events of. Launches
+// stop and restore when ctx is done; Wait waits for that.
+func NewServer(ctx context.Context, m *Manager, token string) *Server {
+	s := &Server{
+		Manager: m,
+		Token:   token,
+		ctx:     ctx,
+		mux:     http.NewServeMux(),
+		subs:    map[chan apiEvent]struct{}{},
+		status:  LaunchStatus{State: LaunchStateIdle},
+	}
+
+	onEvent := m.OnEvent
+	m.OnEvent = func(e Event) {
+		if onEvent != nil {
+			onEvent(e)
+		}
+		s.handleEvent(e)
+	}
+
+	s.mux.HandleFunc("GET /{$}", s.serveWeb)
+	s.mux.HandleFunc("GET /api/mods", s.auth(s.listMods))
+	s.mux.HandleFunc("POST /api/mods/{id}/enable", s.auth(s.setEnabled(true)))
+	s.mux.HandleFunc("POST /api/mods/{id}/disable", s.auth(s.setEnabled(false)))
+	s.mux.HandleFunc("GET /api/mods/{id}/cover", s.auth(s.cover))
+	s.mux.HandleFunc("GET /api/profiles", s.auth(s.listProfiles))
+	s.mux.HandleFunc("POST /api/profiles/{name}/apply", s.auth(s.applyProfile))
+	s.mux.HandleFunc("POST /api/launch", s.auth(s.launch))
+	s.mux.HandleFunc("GET /api/status", s.auth(s.getStatus))
+	s.mux.HandleFunc("GET /api/events", s.auth(s.events))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Wait waits until a running launch has put everything back.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if h, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = h
+		}
+		if s.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New(T_("invalid or missing token")))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// errorStatus maps the errors of the library to HTTP statuses.
+func errorStatus(err error) int {
+	var (
+		modNotFound     *ModNotFoundError
+		profileNotFound *ProfileNotFoundError
+		missingID       *MissingIDError
+		busy            *BusyError
+	)
+	switch {
+	case errors.As(err, &modNotFound), errors.As(err, &profileNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &missingID):
+		return http.StatusBadRequest
+	case errors.As(err, &busy):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *Server) changed() {
+	if s.OnChange != nil {
+		s.OnChange()
+	}
+}
+
+func (s *Server) serveWeb(w http.ResponseWriter, r *http.Request) {
+	if !s.Web {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(webPage)
+}
+
+// listMods returns the mods sorted by name. The database is read again
+// first, so that changes made in the GUI show up.
+func (s *Server) listMods(w http.ResponseWriter, r *http.Request) {
+	if err := s.Manager.Reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	mods := s.Manager.Mods()
+	sortModsByName(mods)
+
+	out := make([]apiMod, 0, len(mods))
+	for _, m := range mods {
+		out = append(out, apiMod{
+			Folder:      m.Folder,
+			Name:        m.DisplayName(),
+			CodeName:    m.DisplayCodeName(),
+			Enabled:     m.Enabled,
+			Size:        m.Size,
+			UpdatedAt:   m.UpdatedAt,
+			NeedsUpdate: m.NeedsUpdate,
+			Cover:       "/api/mods/" + m.Folder + "/cover",
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) setEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Manager.Reload(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.Manager.SetEnabled(r.PathValue("id"), enabled); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		s.changed()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) cover(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	for _, m := range s.Manager.Mods() {
+		if !m.Matches(id) {
+			continue
+		}
+		path, err := GetOrDownloadCover(s.Manager.Config, s.CoverCache, m)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		http.ServeFile(w, r, path)
+		return
+	}
+	writeError(w, http.StatusNotFound, &ModNotFoundError{ID: id})
+}
+
+func (s *Server) listProfiles(w http.ResponseWriter, r *http.Request) {
+	if err := s.Manager.Reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	profiles, active := s.Manager.Profiles()
+	out := make([]apiProfile, 0, len(profiles))
+	for _, p := range profiles {
+		out = append(out, apiProfile{Name: p.Name, Mods: p.Mods, Active: p.Name == active})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) applyProfile(w http.ResponseWriter, r *http.Request) {
+	if err := s.Manager.Reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := s.Manager.ApplyProfile(r.PathValue("name")); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	s.changed()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// launch starts the game and returns at once; the progress is in
+// /api/status and /api/events. The optional body names the mods to launch
+// with, as {"mods": [...]}, or {"vanilla": true} for none; without it the
+// saved selection is used.
+func (s *Server) launch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Mods    []string `json:"mods"`
+		Vanilla bool     `json:"vanilla"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	selection := req.Mods
+	if req.Vanilla {
+		selection = []string{}
+	}
+
+	if err := s.Manager.Reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// A launch from the GUI or the CLI would still be refused by the
+	// manager, but only after the request was accepted.
+	if s.Manager.Busy() {
+		writeError(w, http.StatusConflict, &BusyError{})
+		return
+	}
+
+	s.mu.Lock()
+	if s.status.State != LaunchStateIdle {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, &BusyError{})
+		return
+	}
+	s.status = LaunchStatus{State: LaunchStateLaunching}
+	status := s.status
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := s.Manager.Launch(s.ctx, selection)
+
+		s.mu.Lock()
+		s.status.State = LaunchStateIdle
+		s.status.Message = ""
+		e := apiEvent{Kind: "finished"}
+		if err != nil {
+			s.status.Error = err.Error()
+			e.Error = err.Error()
+		}
+		s.mu.Unlock()
+		s.broadcast(e)
+	}()
+
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleEvent keeps the launch status and passes the event on to the
+// event streams.
+func (s *Server) handleEvent(e Event) {
+	name, ok := eventNames[e.Kind]
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	if s.status.State != LaunchStateIdle {
+		switch e.Kind {
+		case EventLaunching:
+			s.status.Message = e.Message
+		case EventGameRunning:
+			s.status.State = LaunchStateRunning
+		case EventGameExited:
+			s.status.State = LaunchStateRestoring
+		case EventCrashed:
+			s.status.Crash = e.Message
+		}
+	}
+	s.mu.Unlock()
+
+	out := apiEvent{Kind: name, Message: e.Message}
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+	}
+	if e.Kind == EventCopying {
+		out.Done, out.Total = e.Copy.Done, e.Copy.Total
+	}
+	s.broadcast(out)
+}
+
+// broadcast sends e to every event stream. A stream that does not keep up
+// misses it rather than holding up the launch.
+func (s *Server) broadcast(e apiEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// events streams the launch events, starting with the current status.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+
+	ch := make(chan apiEvent, 32)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	status := s.status
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(name string, v any) bool {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, b); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send("status", status) {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case e := <-ch:
+			if !send(e.Kind, e) {
+				slog.Debug("event stream closed", "remote", r.RemoteAddr)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testServer serves a database of two enabled mods, 1 and 2, kept in a
+// temporary home, with the token "secret".
+func testServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	home := t.TempDir()
+	for _, v := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME"} {
+		t.Setenv(v, home+"/"+v)
+	}
+	db := &ModsDB{Mods: []ModEntry{{Folder: "1", Name: "One", Enabled: true}, {Folder: "2", Name: "Two", Enabled: true}}}
+	if err := SaveModsDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewServer(ctx, NewManager(&Config{}, db), "secret")
+	srv := httptest.NewServer(s)
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+	return s, srv
+}
+
+func request(t *testing.T, method, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestServerToken(t *testing.T) {
+	_, srv := testServer(t)
+	for url, want := range map[string]int{
+		srv.URL + "/api/mods":              http.StatusUnauthorized,
+		srv.URL + "/api/mods?token=wrong":  http.StatusUnauthorized,
+		srv.URL + "/api/mods?token=secret": http.StatusOK,
+	} {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", url, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestServerSetEnabled(t *testing.T) {
+	_, srv := testServer(t)
+	enabled := func(folder string) bool {
+		db, err := LoadModsDB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mod, err := findMod(db, folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mod.Enabled
+	}
+
+	if resp := request(t, "POST", srv.URL+"/api/mods/1/disable"); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("disable: status %d", resp.StatusCode)
+	}
+	if enabled("1") || !enabled("2") {
+		t.Error("disable did not save")
+	}
+	if resp := request(t, "POST", srv.URL+"/api/mods/1/enable"); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("enable: status %d", resp.StatusCode)
+	}
+	if !enabled("1") {
+		t.Error("enable did not save")
+	}
+	if resp := request(t, "POST", srv.URL+"/api/mods/3/enable"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown mod: status %d", resp.StatusCode)
+	}
+}
+
+func TestServerLaunchBusy(t *testing.T) {
+	s, srv := testServer(t)
+
+	// Another process holding the lock looks the same as this one.
+	unlock, err := lockLaunch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	if resp := request(t, "POST", srv.URL+"/api/launch"); resp.StatusCode != http.StatusConflict {
+		t.Errorf("status %d", resp.StatusCode)
+	}
+	if s.status.State != LaunchStateIdle {
+		t.Errorf("state = %s", s.status.State)
+	}
+}
+
+func TestServerEvents(t *testing.T) {
+	s, srv := testServer(t)
+	resp := request(t, "GET", srv.URL+"/api/events")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	next := func() (string, string) {
+		var event, data string
+		for lines.Scan() && lines.Text() != "" {
+			if v, ok := strings.CutPrefix(lines.Text(), "event: "); ok {
+				event = v
+			} else if v, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+				data = v
+			}
+		}
+		return event, data
+	}
+
+	if event, data := next(); event != "status" || !strings.Contains(data, `"state":"idle"`) {
+		t.Errorf("first event = %s %s", event, data)
+	}
+	s.Manager.emit(EventInfo, "hello", nil)
+	if event, data := next(); event != "info" || !strings.Contains(data, `"message":"hello"`) {
+		t.Errorf("event = %s %s", event, data)
+	}
+}
@@ -0,0 +1,205 @@
+package lib
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	modListVersion = 1
+	// compactPrefix marks a copy-paste mod list string, which goes on
+	// with its version, the app ID and the packed IDs:
+	// herbarium:2:<app id>:<data>. Version 1 had no app ID and was only
+	// made for Everlasting Summer.
+	compactPrefix  = "herbarium:"
+	compactVersion = "2"
+)
+
+// ModList is the portable form of a set of mods, meant to be shared
+// between players.
+type ModList struct {
+	Version int         `yaml:"version" json:"version"`
+	AppID   string      `yaml:"app_id" json:"app_id"`
+	Name    string      `yaml:"name,omitempty" json:"name,omitempty"`
+	Mods    []SharedMod `yaml:"mods" json:"mods"`
+}
+
+type SharedMod struct {
+	ID       string `yaml:"id" json:"id"`
+	CodeName string `yaml:"codename,omitempty" json:"codename,omitempty"`
+	Name     string `yaml:"name,omitempty" json:"name,omitempty"`
+}
+
+// ImportResult tells which mods of an imported list are installed.
+type ImportResult struct {
+	Applied []string
+	Missing []SharedMod
+}
+
+func WorkshopURL(id string) string {
+	return "https://steamcommunity.com/sharedfiles/filedetails/?id=" + id
+}
+
+// ExportModList builds a list from the enabled mods, or from the named
+// profile if one is given.
+func ExportModList(db *ModsDB, profile string) (*ModList, error) {
+	folders := EnabledFolders(db)
+	if profile != "" {
+		p, err := FindProfile(db, profile)
+		if err != nil {
+			return nil, err
+		}
+		folders = p.Mods
+	}
+
+	known := map[string]ModEntry{}
+	for _, m := range db.Mods {
+		known[m.Folder] = m
+	}
+
+	list := &ModList{Version: modListVersion, AppID: CurrentGame().AppID, Name: profile}
+	for _, f := range folders {
+		m := known[f]
+		list.Mods = append(list.Mods, SharedMod{ID: f, CodeName: m.CodeName, Name: m.DisplayName()})
+	}
+	return list, nil
+}
+
+// WriteModList saves the list as JSON if the file name ends in .json and as
+// YAML otherwise.
+func WriteModList(list *ModList, path string) error {
+	var b []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		b, err = json.MarshalIndent(list, "", "  ")
+	} else {
+		b, err = yaml.Marshal(list)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// ReadModList loads a list from a YAML or JSON file, or decodes it from a
+// compact string.
+func ReadModList(src string) (*ModList, error) {
+	if strings.HasPrefix(strings.TrimSpace(src), compactPrefix) {
+		return DecodeModList(src)
+	}
+
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var list ModList
+	if err := yaml.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	if err := checkModListApp(list.AppID); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// checkModListApp refuses a list made for another game. Lists written by
+// hand may leave the app ID out.
+func checkModListApp(appID string) error {
+	if appID != "" && appID != CurrentGame().AppID {
+		return errors.New(T_("mod list is for another game"))
+	}
+	return nil
+}
+
+// EncodeModList packs the app ID and the Workshop IDs of a list into a
+// short string that can be pasted into a chat.
+func EncodeModList(list *ModList) string {
+	ids := make([]string, 0, len(list.Mods))
+	for _, m := range list.Mods {
+		ids = append(ids, m.ID)
+	}
+
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write([]byte(strings.Join(ids, ",")))
+	w.Close()
+
+	return compactPrefix + compactVersion + ":" + list.AppID + ":" +
+		base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+// DecodeModList unpacks a string made by EncodeModList, refusing one made
+// for another game.
+func DecodeModList(s string) (*ModList, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), compactPrefix)
+	version, rest, _ := strings.Cut(rest, ":")
+	var appID, data string
+	switch {
+	case !ok:
+	case version == "1":
+		appID, data = everlastingSummer.AppID, rest
+	case version == compactVersion:
+		appID, data, ok = strings.Cut(rest, ":")
+	default:
+		ok = false
+	}
+	if !ok || appID == "" || data == "" {
+		return nil, errors.New(T_("not a mod list string"))
+	}
+	if err := checkModListApp(appID); err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ModList{Version: modListVersion, AppID: appID}
+	for id := range strings.SplitSeq(string(ids), ",") {
+		if id != "" {
+			list.Mods = append(list.Mods, SharedMod{ID: id})
+		}
+	}
+	return list, nil
+}
+
+// ImportModList enables exactly the installed mods of the list, or saves
+// them as a profile when profile is not empty.
+func ImportModList(db *ModsDB, list *ModList, profile string) (*ImportResult, error) {
+	installed := map[string]bool{}
+	for _, m := range db.Mods {
+		installed[m.Folder] = true
+	}
+
+	res := &ImportResult{}
+	for _, m := range list.Mods {
+		if installed[m.ID] {
+			res.Applied = append(res.Applied, m.ID)
+		} else {
+			res.Missing = append(res.Missing, m)
+		}
+	}
+
+	if profile != "" {
+		return res, SaveProfile(db, profile, res.Applied)
+	}
+
+	setEnabledSet(db, res.Applied)
+	db.ActiveProfile = ""
+	return res, nil
+}
@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testModList() *ModList {
+	return &ModList{Version: modListVersion, AppID: CurrentGame().AppID, Name: "fav", Mods: []SharedMod{
+		{ID: "111", CodeName: "one", Name: "One"},
+		{ID: "222", Name: "Two"},
+	}}
+}
+
+// withGame makes a game with appID current until the test ends.
+func withGame(t *testing.T, appID string) {
+	t.Helper()
+	old := currentGame
+	g := everlastingSummer
+	g.ID, g.AppID = "other", appID
+	currentGame = &g
+	t.Cleanup(func() { currentGame = old })
+}
+
+func TestModListFile(t *testing.T) {
+	var paths []string
+	for _, name := range []string{"mods.yaml", "mods.json"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := WriteModList(testModList(), path); err != nil {
+			t.Fatal(err)
+		}
+		list, err := ReadModList(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(list, testModList()) {
+			t.Errorf("%s: read %+v", name, list)
+		}
+		paths = append(paths, path)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("mods: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadModList(bad); err == nil {
+		t.Error("malformed file read")
+	}
+
+	withGame(t, "999")
+	for _, path := range paths {
+		if _, err := ReadModList(path); err == nil {
+			t.Errorf("%s: read for another game", filepath.Base(path))
+		}
+	}
+}
+
+func TestModListString(t *testing.T) {
+	s := EncodeModList(testModList())
+	if !strings.HasPrefix(s, "herbarium:2:"+CurrentGame().AppID+":") {
+		t.Fatalf("string = %s", s)
+	}
+	list, err := ReadModList(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Mods) != 2 || list.Mods[0].ID != "111" || list.Mods[1].ID != "222" || list.AppID != CurrentGame().AppID {
+		t.Errorf("decoded %+v", list)
+	}
+
+	withGame(t, "999")
+	if _, err := DecodeModList(s); err == nil {
+		t.Error("decoded for another game")
+	}
+}
+
+func TestModListStringVersion1(t *testing.T) {
+	// Version 1 strings were only made for Everlasting Summer.
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write([]byte("111,222"))
+	w.Close()
+	s := "herbarium:1:" + base64.RawURLEncoding.EncodeToString(buf.Bytes())
+
+	if list, err := DecodeModList(s); err != nil || len(list.Mods) != 2 {
+		t.Errorf("decoded %+v, %v", list, err)
+	}
+	withGame(t, "999")
+	if _, err := DecodeModList(s); err == nil {
+		t.Error("decoded for another game")
+	}
+}
+
+func TestDecodeModListMalformed(t *testing.T) {
+	app := CurrentGame().AppID
+	for _, s := range []string{
+		"",
+		"mods.yaml",
+		"herbarium:",
+		"herbarium:3:" + app + ":AAAA",
+		"herbarium:2:" + app,
+		"herbarium:2::AAAA",
+		"herbarium:2:" + app + ":",
+		"herbarium:2:" + app + ":not base64!",
+		"herbarium:2:" + app + ":AAAA",
+	} {
+		if _, err := DecodeModList(s); err == nil {
+			t.Errorf("%q decoded", s)
+		}
+	}
+}
@@ -1,88 +1,121 @@
 package lib
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
+// defaultSteamAPI is the Steam Web API endpoint used unless steam_api in
+// the config points at another server.
+const defaultSteamAPI = "https://api.steampowered.com"
+
+// steamAPIBase returns the endpoint configured in cfg, which may be nil.
+func steamAPIBase(cfg *Config) string {
+	if cfg != nil && cfg.SteamAPI != "" {
+		return strings.TrimSuffix(cfg.SteamAPI, "/")
+	}
+	return defaultSteamAPI
+}
+
+type publishedFileDetails struct {
+	PublishedFileID string `json:"publishedfileid"`
+	Result          int    `json:"result"`
+	Title           string `json:"title"`
+	PreviewURL      string `json:"preview_url"`
+}
+
 type steamRespGetPublishedFileDetails struct {
 	Response struct {
-		PublishedFileDetails []struct {
-			Title      string `json:"title"`
-			PreviewURL string `json:"preview_url"`
-		} `json:"publishedfiledetails"`
+		PublishedFileDetails []publishedFileDetails `json:"publishedfiledetails"`
 	} `json:"response"`
 }
 
-func FetchSteamTitle(id string) (string, error) {
-	body := bytes.NewBufferString(
-		fmt.Sprintf("itemcount=1&publishedfileids[0]=%s", id),
-	)
+type steamRespGetCollectionDetails struct {
+	Response struct {
+		CollectionDetails []struct {
+			PublishedFileID string `json:"publishedfileid"`
+			Result          int    `json:"result"`
+			Children        []struct {
+				PublishedFileID string `json:"publishedfileid"`
+				SortOrder       int    `json:"sortorder"`
+				FileType        int    `json:"filetype"`
+			} `json:"children"`
+		} `json:"collectiondetails"`
+	} `json:"response"`
+}
 
-	req, err := http.NewRequest("POST",
-		"https://api.steampowered.com/ISteamRemoteStorage/GetPublishedFileDetails/v1/",
-		body)
+// callSteamRemoteStorage posts a form to an ISteamRemoteStorage method and
+// decodes the JSON answer into out.
+func callSteamRemoteStorage(ctx context.Context, cfg *Config, method string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		steamAPIBase(cfg)+"/ISteamRemoteStorage/"+method+"/v1/",
+		strings.NewReader(form.Encode()))
 	if err != nil {
-		return "", err
+		return err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("steam api: %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func fetchPublishedFileDetails(ctx context.Context, cfg *Config, ids []string) ([]publishedFileDetails, error) {
+	form := url.Values{}
+	form.Set("itemcount", strconv.Itoa(len(ids)))
+	for i, id := range ids {
+		form.Set(fmt.Sprintf("publishedfileids[%d]", i), id)
+	}
+
 	var sr steamRespGetPublishedFileDetails
-	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
-		return "", err
+	if err := callSteamRemoteStorage(ctx, cfg, "GetPublishedFileDetails", form, &sr); err != nil {
+		return nil, err
 	}
 
 	if len(sr.Response.PublishedFileDetails) == 0 {
-		return "", fmt.Errorf("not found")
+		return nil, fmt.Errorf("not found")
 	}
 
-	return sr.Response.PublishedFileDetails[0].Title, nil
+	return sr.Response.PublishedFileDetails, nil
 }
 
-func FetchSteamCoverURL(id string) (string, error) {
-	body := bytes.NewBufferString(
-		fmt.Sprintf("itemcount=1&publishedfileids[0]=%s", id),
-	)
-
-	req, _ := http.NewRequest(
-		"POST",
-		"https://api.steampowered.com/ISteamRemoteStorage/GetPublishedFileDetails/v1/",
-		body,
-	)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := http.DefaultClient.Do(req)
+func FetchSteamTitle(ctx context.Context, cfg *Config, id string) (string, error) {
+	details, err := fetchPublishedFileDetails(ctx, cfg, []string{id})
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	var sr steamRespGetPublishedFileDetails
-	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
-		return "", err
-	}
+	return details[0].Title, nil
+}
 
-	if len(sr.Response.PublishedFileDetails) == 0 {
-		return "", fmt.Errorf("not found")
+func FetchSteamCoverURL(ctx context.Context, cfg *Config, id string) (string, error) {
+	details, err := fetchPublishedFileDetails(ctx, cfg, []string{id})
+	if err != nil {
+		return "", err
 	}
 
-	url := sr.Response.PublishedFileDetails[0].PreviewURL
-	if url == "" {
+	preview := details[0].PreviewURL
+	if preview == "" {
 		return "", fmt.Errorf("no preview")
 	}
 
-	return url, nil
+	return preview, nil
 }
 
 func ModCoverCachePath(appID, folder string) (string, error) {
@@ -104,7 +137,13 @@ func ModCoverCachePath(appID, folder string) (string, error) {
 	return filepath.Join(dir, "cover"), nil
 }
 
-func GetOrDownloadCover(appID string, mod ModEntry) (string, error) {
+func GetOrDownloadCover(cfg *Config, appID string, mod ModEntry) (string, error) {
+	if mod.Overrides.Cover != "" {
+		if _, err := os.Stat(mod.Overrides.Cover); err == nil {
+			return mod.Overrides.Cover, nil
+		}
+	}
+
 	cachePath, err := ModCoverCachePath(appID, mod.Folder)
 	if err != nil {
 		return "", err
@@ -114,7 +153,7 @@ func GetOrDownloadCover(appID string, mod ModEntry) (string, error) {
 		return cachePath, nil
 	}
 
-	url, err := FetchSteamCoverURL(mod.Folder)
+	url, err := FetchSteamCoverURL(context.Background(), cfg, mod.Folder)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SteamInstall describes where Steam keeps the game and its Workshop items.
+type SteamInstall struct {
+	Roots    []string
+	Library  string
+	GameDir  string
+	Workshop string
+	Flatpak  bool
+}
+
+// steamRootCandidates lists the places Steam is installed to on Linux,
+// natively and as a Flatpak.
+func steamRootCandidates() []string {
+	home, _ := os.UserHomeDir()
+	return []string{
+		filepath.Join(home, ".steam/steam"),
+		filepath.Join(home, ".steam/root"),
+		filepath.Join(home, ".local/share/Steam"),
+		filepath.Join(home, ".var/app", flatpakSteamID, ".local/share/Steam"),
+		filepath.Join(home, ".var/app", flatpakSteamID, "data/Steam"),
+	}
+}
+
+// SteamRoots returns every existing Steam installation, with symlinks
+// resolved and duplicates removed.
+func SteamRoots() []string {
+	seen := map[string]bool{}
+	var roots []string
+	for _, c := range steamRootCandidates() {
+		dir, err := filepath.EvalSymlinks(c)
+		if err != nil || seen[dir] {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, "steamapps")); err != nil || !info.IsDir() {
+			continue
+		}
+		seen[dir] = true
+		roots = append(roots, dir)
+	}
+	return roots
+}
+
+// SteamLibraries returns the library folders registered in a Steam root,
+// starting with the root itself.
+func SteamLibraries(root string) []string {
+	libs := []string{root}
+	seen := map[string]bool{root: true}
+
+	kv, err := ParseKeyValuesFile(filepath.Join(root, "steamapps", "libraryfolders.vdf"))
+	if err != nil {
+		return libs
+	}
+
+	folders := kv.Get("libraryfolders")
+	if folders == nil {
+		return libs
+	}
+	for _, f := range folders.Children {
+		if _, err := strconv.Atoi(f.Key); err != nil {
+			continue
+		}
+		// Old files map the index straight to the path, new ones
+		// store it in a "path" key along with the installed apps.
+		path := f.Value
+		if f.Children != nil {
+			path = f.String("path")
+		}
+		if path == "" {
+			continue
+		}
+		if dir, err := filepath.EvalSymlinks(path); err == nil {
+			path = dir
+		}
+		if !seen[path] {
+			seen[path] = true
+			libs = append(libs, path)
+		}
+	}
+	return libs
+}
+
+func libraryHasApp(library, appID string) bool {
+	_, err := os.Stat(appManifestPath(library, appID))
+	return err == nil
+}
+
+func appManifestPath(library, appID string) string {
+	return filepath.Join(library, "steamapps", "appmanifest_"+appID+".acf")
+}
+
+// DetectSteamInstall looks through all Steam roots and their libraries for
+// the library holding the game, its install dir and its Workshop content.
+func DetectSteamInstall() (*SteamInstall, error) {
+	home, _ := os.UserHomeDir()
+	flatpakRoot := filepath.Join(home, ".var/app", flatpakSteamID)
+
+	inst := &SteamInstall{Roots: SteamRoots()}
+	if len(inst.Roots) == 0 {
+		return inst, errors.New(T_("no Steam installation found"))
+	}
+
+	appID := CurrentGame().AppID
+	for _, root := range inst.Roots {
+		for _, lib := range SteamLibraries(root) {
+			if !libraryHasApp(lib, appID) {
+				continue
+			}
+
+			inst.Library = lib
+			inst.Flatpak = strings.HasPrefix(root, flatpakRoot+string(filepath.Separator))
+			inst.Workshop = filepath.Join(lib, "steamapps", "workshop", "content", appID)
+
+			kv, err := ParseKeyValuesFile(appManifestPath(lib, appID))
+			if err == nil {
+				if dir := kv.String("AppState", "installdir"); dir != "" {
+					inst.GameDir = filepath.Join(lib, "steamapps", "common", dir)
+				}
+			}
+			return inst, nil
+		}
+	}
+
+	return inst, fmt.Errorf(T_("app %s not found in any Steam library"), appID)
+}
@@ -3,10 +3,21 @@ package lib
 import "time"
 
 type Config struct {
+	Launcher    string   `yaml:"launcher,omitempty"`
 	GameExe     string   `yaml:"game_exe"`
 	Args        []string `yaml:"args,omitempty"`
+	GameDir     string   `yaml:"game_dir,omitempty"`
+	Runner      string   `yaml:"runner,omitempty"`
+	WinePrefix  string   `yaml:"wine_prefix,omitempty"`
+	ProcessName string   `yaml:"process_name,omitempty"`
 	Root        string   `yaml:"workshop_root"`
 	DisabledDir string   `yaml:"disabled_dir"`
+	SteamAPI    string   `yaml:"steam_api,omitempty"`
+
+	SaveIsolation string   `yaml:"save_isolation,omitempty"`
+	SaveDirs      []string `yaml:"save_dirs,omitempty"`
+
+	Hooks HooksConfig `yaml:"hooks,omitempty"`
 }
 
 type ModEntry struct {
@@ -15,8 +26,25 @@ type ModEntry struct {
 	Folder       string    `yaml:"folder"`
 	Enabled      bool      `yaml:"enabled"`
 	DiscoveredAt time.Time `yaml:"discovered_at"`
+	Size         int64     `yaml:"size,omitempty"`
+	UpdatedAt    time.Time `yaml:"updated_at,omitempty"`
+	NeedsUpdate  bool      `yaml:"needs_update,omitempty"`
+	Unsubscribed bool      `yaml:"unsubscribed,omitempty"`
+	Fingerprint  string    `yaml:"fingerprint,omitempty"`
+
+	Overrides ModOverrides `yaml:"overrides,omitempty"`
+}
+
+// ModOverrides are set by the user and win over what scans extract, which
+// never touch them. Empty fields are not overridden.
+type ModOverrides struct {
+	Name     string `yaml:"name,omitempty"`
+	CodeName string `yaml:"codename,omitempty"`
+	Cover    string `yaml:"cover,omitempty"`
 }
 
 type ModsDB struct {
-	Mods []ModEntry `yaml:"mods"`
+	Mods          []ModEntry `yaml:"mods"`
+	Profiles      []Profile  `yaml:"profiles,omitempty"`
+	ActiveProfile string     `yaml:"active_profile,omitempty"`
 }
@@ -0,0 +1,232 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyValue is a node of Valve's KeyValues text format, used by Steam for
+// .vdf and .acf files. A node has either a string Value or Children.
+type KeyValue struct {
+	Key      string
+	Value    string
+	Children []*KeyValue
+}
+
+// Get returns the first child with the given key. Keys are compared
+// case-insensitively, as Steam does.
+func (kv *KeyValue) Get(key string) *KeyValue {
+	if kv == nil {
+		return nil
+	}
+	for _, c := range kv.Children {
+		if strings.EqualFold(c.Key, key) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Nodes returns the children of kv, or nil if kv is nil, so that a missing
+// section reads as an empty one.
+func (kv *KeyValue) Nodes() []*KeyValue {
+	if kv == nil {
+		return nil
+	}
+	return kv.Children
+}
+
+// Find follows a path of keys and returns the node at its end.
+func (kv *KeyValue) Find(path ...string) *KeyValue {
+	for _, key := range path {
+		kv = kv.Get(key)
+	}
+	return kv
+}
+
+// String returns the value at the given path, or "" if there is none.
+func (kv *KeyValue) String(path ...string) string {
+	if n := kv.Find(path...); n != nil {
+		return n.Value
+	}
+	return ""
+}
+
+func ParseKeyValuesFile(path string) (*KeyValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseKeyValues(f)
+}
+
+// ParseKeyValues reads a KeyValues document. The returned node has no key
+// and holds the top-level entries as children.
+func ParseKeyValues(r io.Reader) (*KeyValue, error) {
+	p := &kvParser{r: bufio.NewReader(r), line: 1}
+	root := &KeyValue{}
+	if err := p.parseChildren(root, false); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type kvParser struct {
+	r    *bufio.Reader
+	line int
+}
+
+const (
+	kvString = iota
+	kvOpen
+	kvClose
+	kvEOF
+)
+
+func (p *kvParser) parseChildren(parent *KeyValue, nested bool) error {
+	for {
+		kind, key, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case kvEOF:
+			if nested {
+				return p.errorf("unexpected end of file")
+			}
+			return nil
+		case kvClose:
+			if !nested {
+				return p.errorf("unexpected '}'")
+			}
+			return nil
+		case kvOpen:
+			return p.errorf("unexpected '{'")
+		}
+
+		kind, value, err := p.next()
+		if err != nil {
+			return err
+		}
+		node := &KeyValue{Key: key}
+		switch kind {
+		case kvString:
+			node.Value = value
+		case kvOpen:
+			if err := p.parseChildren(node, true); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("missing value for key %q", key)
+		}
+		parent.Children = append(parent.Children, node)
+	}
+}
+
+func (p *kvParser) next() (kind int, token string, err error) {
+	for {
+		c, err := p.read()
+		if err == io.EOF {
+			return kvEOF, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+
+		switch {
+		case c == '{':
+			return kvOpen, "", nil
+		case c == '}':
+			return kvClose, "", nil
+		case c == '"':
+			s, err := p.readQuoted()
+			return kvString, s, err
+		case c == '/':
+			if n, _ := p.r.Peek(1); len(n) == 1 && n[0] == '/' {
+				p.skipLine()
+				continue
+			}
+			return kvString, p.readBare(c), nil
+		case c == '[':
+			// Platform conditionals such as [$WIN32] are ignored.
+			p.skipUntil(']')
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			return kvString, p.readBare(c), nil
+		}
+	}
+}
+
+func (p *kvParser) read() (rune, error) {
+	c, _, err := p.r.ReadRune()
+	if c == '\n' {
+		p.line++
+	}
+	return c, err
+}
+
+func (p *kvParser) readQuoted() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := p.read()
+		if err != nil {
+			return "", p.errorf("unterminated string")
+		}
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			e, err := p.read()
+			if err != nil {
+				return "", p.errorf("unterminated string")
+			}
+			switch e {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			default:
+				b.WriteRune(e)
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
+
+func (p *kvParser) readBare(first rune) string {
+	var b strings.Builder
+	b.WriteRune(first)
+	for {
+		c, _, err := p.r.ReadRune()
+		if err != nil {
+			return b.String()
+		}
+		if strings.ContainsRune(" \t\r\n{}\"", c) {
+			p.r.UnreadRune()
+			return b.String()
+		}
+		b.WriteRune(c)
+	}
+}
+
+func (p *kvParser) skipLine() {
+	p.skipUntil('\n')
+}
+
+func (p *kvParser) skipUntil(end rune) {
+	for {
+		c, err := p.read()
+		if err != nil || c == end {
+			return
+		}
+	}
+}
+
+func (p *kvParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("keyvalues: line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	for _, tt := range []struct {
+		name, doc string
+		path      []string
+		want      string
+	}{
+		{"quoted", `"a" { "b" "c" }`, []string{"a", "b"}, "c"},
+		{"bare", "a { b c }", []string{"a", "b"}, "c"},
+		{"case-insensitive keys", `"AppState" { "InstallDir" "x" }`, []string{"appstate", "installdir"}, "x"},
+		{"escapes", `"a" "one\"two\\three\nfour\tfive"`, []string{"a"}, "one\"two\\three\nfour\tfive"},
+		{"comments", "// header\n\"a\" // trailing\n{\n\t\"b\" \"c\" // more\n}\n", []string{"a", "b"}, "c"},
+		{"slash in a value", `"a" "C:/x//y"`, []string{"a"}, "C:/x//y"},
+		{"conditional", `"a" "b" [$WIN32]`, []string{"a"}, "b"},
+		{"first of repeated keys", `"a" "1" "a" "2"`, []string{"a"}, "1"},
+		{"empty value", `"a" ""`, []string{"a"}, ""},
+		{"missing", `"a" { }`, []string{"a", "b"}, ""},
+	} {
+		kv, err := ParseKeyValues(strings.NewReader(tt.doc))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := kv.String(tt.path...); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseKeyValuesErrors(t *testing.T) {
+	for name, doc := range map[string]string{
+		"unclosed block":      `"a" { "b" "c"`,
+		"stray brace":         `"a" "b" }`,
+		"block as key":        `{ "a" "b" }`,
+		"missing value":       `"a" { "b" }`,
+		"unterminated string": `"a" "b`,
+	} {
+		if _, err := ParseKeyValues(strings.NewReader(doc)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// steamRoot writes a Steam root under a temporary home with the given
+// libraryfolders.vdf.
+func steamRoot(t *testing.T, libraryFolders string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	root := filepath.Join(home, ".local", "share", "Steam")
+	writeFiles(t, root, map[string]string{"steamapps/libraryfolders.vdf": libraryFolders}, time.Now())
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestSteamLibraries(t *testing.T) {
+	other := t.TempDir()
+	for name, vdf := range map[string]string{
+		// Before 2021 the index mapped straight to the path.
+		"old": `"LibraryFolders"
+{
+	"TimeNextStatsReport"		"1700000000"
+	"ContentStatsID"		"-123"
+	"1"		"` + other + `"
+}
+`,
+		"new": `"libraryfolders"
+{
+	"0"
+	{
+		"path"		"ROOT"
+		"label"		""
+		"apps"
+		{
+			"228980"		"123"
+		}
+	}
+	"1"
+	{
+		"path"		"` + other + `"
+		"label"		""
+		"contentid"		"42"
+		"totalsize"		"0"
+		"apps"
+		{
+			"331470"		"456"
+		}
+	}
+}
+`,
+	} {
+		root := steamRoot(t, "")
+		vdf = strings.ReplaceAll(vdf, "ROOT", root)
+		if err := os.WriteFile(filepath.Join(root, "steamapps", "libraryfolders.vdf"), []byte(vdf), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := SteamLibraries(root); !slices.Equal(got, []string{root, other}) {
+			t.Errorf("%s: libraries = %v", name, got)
+		}
+	}
+}
+
+func TestDetectSteamInstall(t *testing.T) {
+	appID := CurrentGame().AppID
+	library := t.TempDir()
+	writeFiles(t, library, map[string]string{
+		"steamapps/appmanifest_" + appID + ".acf": `"AppState"
+{
+	"appid"		"` + appID + `"
+	"Universe"		"1"
+	"name"		"Game"
+	"StateFlags"		"4"
+	"installdir"		"Game Dir"
+	"UserConfig"
+	{
+		"language"		"english"
+	}
+}
+`,
+	}, time.Now())
+	root := steamRoot(t, `"libraryfolders" { "0" { "path" "`+library+`" } }`)
+
+	inst, err := DetectSteamInstall()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(inst.Roots, []string{root}) || inst.Library != library || inst.Flatpak {
+		t.Errorf("install = %+v", inst)
+	}
+	if want := filepath.Join(library, "steamapps", "common", "Game Dir"); inst.GameDir != want {
+		t.Errorf("GameDir = %s, want %s", inst.GameDir, want)
+	}
+	if want := filepath.Join(library, "steamapps", "workshop", "content", appID); inst.Workshop != want {
+		t.Errorf("Workshop = %s, want %s", inst.Workshop, want)
+	}
+
+	os.Remove(filepath.Join(library, "steamapps", "appmanifest_"+appID+".acf"))
+	if _, err := DetectSteamInstall(); err == nil {
+		t.Error("found a game that is not installed")
+	}
+}
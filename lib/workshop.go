@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// workshopItem is what Steam knows about one subscribed Workshop item.
+type workshopItem struct {
+	Size        int64
+	UpdatedAt   time.Time
+	NeedsUpdate bool
+	Installed   bool
+}
+
+// workshopManifestPath returns appworkshop_<appid>.acf for a Workshop
+// content dir such as <library>/steamapps/workshop/content/<appid>.
+func workshopManifestPath(root string) string {
+	return filepath.Join(root, "..", "..", "appworkshop_"+CurrentGame().AppID+".acf")
+}
+
+// loadWorkshopManifest reads the Workshop items of the current game. A
+// section that is missing, as in the manifest of a fresh subscription, has
+// no items.
+func loadWorkshopManifest(root string) (map[string]workshopItem, error) {
+	kv, err := ParseKeyValuesFile(workshopManifestPath(root))
+	if err != nil {
+		return nil, err
+	}
+
+	state := kv.Get("AppWorkshop")
+	items := map[string]workshopItem{}
+
+	for _, n := range state.Get("WorkshopItemsInstalled").Nodes() {
+		items[n.Key] = workshopItem{
+			Size:      parseInt(n.String("size")),
+			UpdatedAt: parseUnix(n.String("timeupdated")),
+			Installed: true,
+		}
+	}
+
+	// Details list every subscription, including ones that are not (fully)
+	// downloaded yet. An item is outdated when Steam already knows about a
+	// newer manifest than the installed one.
+	for _, n := range state.Get("WorkshopItemDetails").Nodes() {
+		item := items[n.Key]
+		if !item.Installed {
+			item.NeedsUpdate = true
+		}
+		if latest := n.String("latest_manifest"); latest != "" && latest != n.String("manifest") {
+			item.NeedsUpdate = true
+		}
+		if latest := parseInt(n.String("latest_timeupdated")); latest > parseInt(n.String("timeupdated")) {
+			item.NeedsUpdate = true
+		}
+		if item.UpdatedAt.IsZero() {
+			item.UpdatedAt = parseUnix(n.String("timeupdated"))
+		}
+		items[n.Key] = item
+	}
+
+	return items, nil
+}
+
+// mergeWorkshopState copies size, update time and update state from the
+// Workshop manifest into the mod list. Mods missing from the manifest are
+// marked as unsubscribed and no longer need an update; their size and
+// update time are kept. Without a readable manifest nothing is changed.
+func mergeWorkshopState(root string, mods []ModEntry) {
+	items, err := loadWorkshopManifest(root)
+	if err != nil {
+		return
+	}
+
+	for i := range mods {
+		item, ok := items[mods[i].Folder]
+		mods[i].Unsubscribed = !ok
+		if !ok {
+			mods[i].NeedsUpdate = false
+			continue
+		}
+		mods[i].Size = item.Size
+		mods[i].UpdatedAt = item.UpdatedAt
+		mods[i].NeedsUpdate = item.NeedsUpdate
+	}
+}
+
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func parseUnix(s string) time.Time {
+	n := parseInt(s)
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(n, 0).UTC()
+}
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// workshopRoot returns a Workshop content dir whose manifest holds acf.
+func workshopRoot(t *testing.T, acf string) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "steamapps", "workshop", "content", CurrentGame().AppID)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(workshopManifestPath(root), []byte(acf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestLoadWorkshopManifest(t *testing.T) {
+	root := workshopRoot(t, `"AppWorkshop"
+{
+	"appid"		"331470"
+	"WorkshopItemsInstalled"
+	{
+		"111"
+		{
+			"size"		"2048"
+			"timeupdated"		"1700000000"
+			"manifest"		"1"
+		}
+	}
+	"WorkshopItemDetails"
+	{
+		"111"
+		{
+			"manifest"		"1"
+			"timeupdated"		"1700000000"
+			"latest_timeupdated"		"1700000500"
+			"latest_manifest"		"2"
+		}
+		"222"
+		{
+			"manifest"		"3"
+			"timeupdated"		"1600000000"
+		}
+	}
+}
+`)
+	items, err := loadWorkshopManifest(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it := items["111"]; !it.Installed || it.Size != 2048 || !it.NeedsUpdate || it.UpdatedAt.Unix() != 1700000000 {
+		t.Errorf("111 = %+v", it)
+	}
+	if it := items["222"]; it.Installed || !it.NeedsUpdate {
+		t.Errorf("222 = %+v", it)
+	}
+}
+
+func TestLoadWorkshopManifestMissingSections(t *testing.T) {
+	for name, acf := range map[string]string{
+		"empty":          ``,
+		"no AppWorkshop": `"Other" { "a" "b" }`,
+		"no items":       `"AppWorkshop" { "appid" "331470" }`,
+		"only details":   `"AppWorkshop" { "WorkshopItemDetails" { "222" { "manifest" "3" } } }`,
+	} {
+		items, err := loadWorkshopManifest(workshopRoot(t, acf))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if name == "only details" && !items["222"].NeedsUpdate {
+			t.Errorf("%s: items = %+v", name, items)
+		} else if name != "only details" && len(items) != 0 {
+			t.Errorf("%s: items = %+v", name, items)
+		}
+	}
+}